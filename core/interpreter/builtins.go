@@ -0,0 +1,795 @@
+package interpreter
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/salillakra/npp/frontend/lexer"
+	"github.com/salillakra/npp/frontend/parser"
+)
+
+// builtins maps builtin names to their implementations. Builtins that need
+// interpreter state are methods added per instance in New.
+var builtins = map[string]*BuiltinObject{
+	"len":          {Name: "len", Fn: builtinLen},
+	"byte_len":     {Name: "byte_len", Fn: builtinByteLen},
+	"has_key":      {Name: "has_key", Fn: builtinHasKey},
+	"entries":      {Name: "entries", Fn: builtinEntries},
+	"fhenk":        {Name: "fhenk", Fn: builtinFhenk},
+	"chr":          {Name: "chr", Fn: builtinChr},
+	"ord":          {Name: "ord", Fn: builtinOrd},
+	"substr":       {Name: "substr", Fn: builtinSubstr},
+	"replace":      {Name: "replace", Fn: builtinReplace},
+	"type":         {Name: "type", Fn: builtinType},
+	"reverse":      {Name: "reverse", Fn: builtinReverse},
+	"pretty":       {Name: "pretty", Fn: builtinPretty},
+	"to_json":      {Name: "to_json", Fn: builtinToJSON},
+	"from_json":    {Name: "from_json", Fn: builtinFromJSON},
+	"error_line":   {Name: "error_line", Fn: builtinErrorLine},
+	"error_col":    {Name: "error_col", Fn: builtinErrorCol},
+	"clone":        {Name: "clone", Fn: builtinClone},
+	"starts_with":  {Name: "starts_with", Fn: builtinStartsWith},
+	"ends_with":    {Name: "ends_with", Fn: builtinEndsWith},
+	"isqrt":        {Name: "isqrt", Fn: builtinIsqrt},
+	"sqrt":         {Name: "sqrt", Fn: builtinSqrt},
+	"fmt_duration": {Name: "fmt_duration", Fn: builtinFmtDuration},
+	"bool":         {Name: "bool", Fn: builtinBool},
+	"loose_eq":     {Name: "loose_eq", Fn: builtinLooseEq},
+	"same":         {Name: "same", Fn: builtinSame},
+	"digit_sum":    {Name: "digit_sum", Fn: builtinDigitSum},
+	"num_digits":   {Name: "num_digits", Fn: builtinNumDigits},
+	"gcd":          {Name: "gcd", Fn: builtinGcd},
+	"lcm":          {Name: "lcm", Fn: builtinLcm},
+	"zip":          {Name: "zip", Fn: builtinZip},
+	"enumerate":    {Name: "enumerate", Fn: builtinEnumerate},
+	"assert":       {Name: "assert", Fn: builtinAssert},
+	"printf":       {Name: "printf", Fn: builtinPrintf},
+}
+
+// checkArgs returns an error if the builtin got the wrong number of arguments.
+func checkArgs(tok lexer.Token, name string, args []Object, want int) *ErrorObject {
+	if len(args) != want {
+		return newError(tok, "%s expects %d argument(s), got %d", name, want, len(args))
+	}
+	return nil
+}
+
+// builtinLen returns the number of elements of an array or hash, or the
+// number of characters (runes) of a string (e.g., len([1, 2]) is 2).
+func builtinLen(tok lexer.Token, args ...Object) Object {
+	if err := checkArgs(tok, "len", args, 1); err != nil {
+		return err
+	}
+	switch arg := args[0].(type) {
+	case *ArrayObject:
+		return newInt(int64(len(arg.Elements)))
+	case *HashObject:
+		return newInt(int64(len(arg.Keys)))
+	case *StringObject:
+		return newInt(int64(utf8.RuneCountInString(arg.Value)))
+	default:
+		return newError(tok, "len not supported for %s", TypeName(arg))
+	}
+}
+
+// builtinByteLen returns the size of a string in UTF-8 bytes (e.g., byte_len("é") is 2).
+func builtinByteLen(tok lexer.Token, args ...Object) Object {
+	if err := checkArgs(tok, "byte_len", args, 1); err != nil {
+		return err
+	}
+	str, ok := args[0].(*StringObject)
+	if !ok {
+		return newError(tok, "byte_len expects a string, got %s", TypeName(args[0]))
+	}
+	return newInt(int64(len(str.Value)))
+}
+
+// builtinHasKey reports whether a hash contains the given key (e.g., has_key(h, "a")).
+func builtinHasKey(tok lexer.Token, args ...Object) Object {
+	if err := checkArgs(tok, "has_key", args, 2); err != nil {
+		return err
+	}
+	hash, ok := args[0].(*HashObject)
+	if !ok {
+		return newError(tok, "has_key expects a hash, got %s", args[0].String())
+	}
+	key, ok := args[1].(Hashable)
+	if !ok {
+		return newError(tok, "Unusable as hash key: %s", args[1].String())
+	}
+	_, found := hash.Pairs[key.HashKey()]
+	return nativeBoolToBoolObject(found)
+}
+
+// builtinEntries returns a hash's [key, value] pairs in insertion order (e.g., entries(h)).
+func builtinEntries(tok lexer.Token, args ...Object) Object {
+	if err := checkArgs(tok, "entries", args, 1); err != nil {
+		return err
+	}
+	hash, ok := args[0].(*HashObject)
+	if !ok {
+		return newError(tok, "entries expects a hash, got %s", args[0].String())
+	}
+	pairs := make([]Object, 0, len(hash.Keys))
+	for _, hk := range hash.Keys {
+		pair := hash.Pairs[hk]
+		pairs = append(pairs, &ArrayObject{Elements: []Object{pair.Key, pair.Value}})
+	}
+	return &ArrayObject{Elements: pairs}
+}
+
+// builtinFhenk raises a script error carrying msg and the call-site position
+// (e.g., fhenk("boom")). Given an error caught by pakad, it rethrows that
+// error unchanged, keeping its message and original position.
+func builtinFhenk(tok lexer.Token, args ...Object) Object {
+	if err := checkArgs(tok, "fhenk", args, 1); err != nil {
+		return err
+	}
+	if caught, ok := args[0].(*ErrorObject); ok {
+		rethrown := *caught
+		rethrown.caught = false
+		return &rethrown
+	}
+	return newError(tok, "%s", args[0].String())
+}
+
+// builtinAssert raises an error unless cond is truthy, adding msg if one is
+// given (e.g., assert(len(a) == 3, "three items")).
+func builtinAssert(tok lexer.Token, args ...Object) Object {
+	if len(args) != 1 && len(args) != 2 {
+		return newError(tok, "assert expects 1 or 2 argument(s), got %d", len(args))
+	}
+	if isTruthy(args[0]) {
+		return NULL
+	}
+	if len(args) == 2 {
+		return newError(tok, "Assertion failed: %s", args[1].String())
+	}
+	return newError(tok, "Assertion failed")
+}
+
+// builtinErrorLine returns the line a caught error was raised on (e.g., error_line(e)).
+func builtinErrorLine(tok lexer.Token, args ...Object) Object {
+	if err := checkArgs(tok, "error_line", args, 1); err != nil {
+		return err
+	}
+	caught, ok := args[0].(*ErrorObject)
+	if !ok {
+		return newError(tok, "error_line expects an error, got %s", TypeName(args[0]))
+	}
+	return newInt(int64(caught.Line))
+}
+
+// builtinErrorCol returns the column a caught error was raised at (e.g., error_col(e)).
+func builtinErrorCol(tok lexer.Token, args ...Object) Object {
+	if err := checkArgs(tok, "error_col", args, 1); err != nil {
+		return err
+	}
+	caught, ok := args[0].(*ErrorObject)
+	if !ok {
+		return newError(tok, "error_col expects an error, got %s", TypeName(args[0]))
+	}
+	return newInt(int64(caught.Column))
+}
+
+// builtinChr returns the single-character string for a code point (e.g., chr(65) is "A").
+func builtinChr(tok lexer.Token, args ...Object) Object {
+	if err := checkArgs(tok, "chr", args, 1); err != nil {
+		return err
+	}
+	n, ok := args[0].(*IntObject)
+	if !ok {
+		return newError(tok, "chr expects an int, got %s", args[0].String())
+	}
+	if n.Value < 0 || n.Value > utf8.MaxRune || !utf8.ValidRune(rune(n.Value)) {
+		return newError(tok, "chr: %d is not a valid code point", n.Value)
+	}
+	return &StringObject{Value: string(rune(n.Value))}
+}
+
+// builtinOrd returns the code point of a single-character string (e.g., ord("A") is 65).
+func builtinOrd(tok lexer.Token, args ...Object) Object {
+	if err := checkArgs(tok, "ord", args, 1); err != nil {
+		return err
+	}
+	str, ok := args[0].(*StringObject)
+	if !ok {
+		return newError(tok, "ord expects a string, got %s", args[0].String())
+	}
+	if utf8.RuneCountInString(str.Value) != 1 {
+		return newError(tok, "ord expects a single character, got %q", str.Value)
+	}
+	r, _ := utf8.DecodeRuneInString(str.Value)
+	return newInt(int64(r))
+}
+
+// builtinSubstr returns up to length characters of s starting at start
+// (e.g., substr("hello", 1, 3) is "ell"). Out-of-range start and length are
+// clamped to the string; a negative length is an error.
+func builtinSubstr(tok lexer.Token, args ...Object) Object {
+	if err := checkArgs(tok, "substr", args, 3); err != nil {
+		return err
+	}
+	str, ok := args[0].(*StringObject)
+	if !ok {
+		return newError(tok, "substr expects a string, got %s", args[0].String())
+	}
+	start, ok1 := args[1].(*IntObject)
+	length, ok2 := args[2].(*IntObject)
+	if !ok1 || !ok2 {
+		return newError(tok, "substr expects int start and length, got %s and %s", args[1].String(), args[2].String())
+	}
+	if length.Value < 0 {
+		return newError(tok, "substr: negative length %d", length.Value)
+	}
+	runes := []rune(str.Value)
+	from := clamp(start.Value, 0, int64(len(runes)))
+	to := clamp(from+length.Value, from, int64(len(runes)))
+	return &StringObject{Value: string(runes[from:to])}
+}
+
+// builtinReplace replaces every occurrence of old in s with new (e.g., replace("a-b", "-", "+")).
+func builtinReplace(tok lexer.Token, args ...Object) Object {
+	if err := checkArgs(tok, "replace", args, 3); err != nil {
+		return err
+	}
+	strs := make([]string, len(args))
+	for idx, arg := range args {
+		str, ok := arg.(*StringObject)
+		if !ok {
+			return newError(tok, "replace expects strings, got %s", arg.String())
+		}
+		strs[idx] = str.Value
+	}
+	return &StringObject{Value: strings.ReplaceAll(strs[0], strs[1], strs[2])}
+}
+
+// builtinStartsWith reports whether s begins with prefix (e.g., starts_with("npp", "n")).
+func builtinStartsWith(tok lexer.Token, args ...Object) Object {
+	s, prefix, err := stringPair(tok, "starts_with", args)
+	if err != nil {
+		return err
+	}
+	return nativeBoolToBoolObject(strings.HasPrefix(s, prefix))
+}
+
+// builtinEndsWith reports whether s ends with suffix (e.g., ends_with("main.npp", ".npp")).
+func builtinEndsWith(tok lexer.Token, args ...Object) Object {
+	s, suffix, err := stringPair(tok, "ends_with", args)
+	if err != nil {
+		return err
+	}
+	return nativeBoolToBoolObject(strings.HasSuffix(s, suffix))
+}
+
+// stringPair checks that a builtin got exactly two strings and returns them.
+func stringPair(tok lexer.Token, name string, args []Object) (string, string, *ErrorObject) {
+	if err := checkArgs(tok, name, args, 2); err != nil {
+		return "", "", err
+	}
+	first, ok1 := args[0].(*StringObject)
+	second, ok2 := args[1].(*StringObject)
+	if !ok1 || !ok2 {
+		return "", "", newError(tok, "%s expects two strings, got %s and %s", name, TypeName(args[0]), TypeName(args[1]))
+	}
+	return first.Value, second.Value, nil
+}
+
+// builtinReverse returns a reversed copy of an array or string, reversing
+// strings by character rather than by byte (e.g., reverse("abc") is "cba").
+func builtinReverse(tok lexer.Token, args ...Object) Object {
+	if err := checkArgs(tok, "reverse", args, 1); err != nil {
+		return err
+	}
+	switch arg := args[0].(type) {
+	case *ArrayObject:
+		n := len(arg.Elements)
+		elements := make([]Object, n)
+		for idx, el := range arg.Elements {
+			elements[n-1-idx] = el
+		}
+		return &ArrayObject{Elements: elements}
+	case *StringObject:
+		runes := []rune(arg.Value)
+		for a, b := 0, len(runes)-1; a < b; a, b = a+1, b-1 {
+			runes[a], runes[b] = runes[b], runes[a]
+		}
+		return &StringObject{Value: string(runes)}
+	default:
+		return newError(tok, "reverse not supported for %s", TypeName(arg))
+	}
+}
+
+// builtinClone returns a deep copy of an array or hash (e.g., clone(h)).
+// Other values are immutable and returned as they are.
+func builtinClone(tok lexer.Token, args ...Object) Object {
+	if err := checkArgs(tok, "clone", args, 1); err != nil {
+		return err
+	}
+	return deepCopy(args[0])
+}
+
+// deepCopy copies arrays and hashes recursively, keeping hash key order.
+func deepCopy(obj Object) Object {
+	switch obj := obj.(type) {
+	case *ArrayObject:
+		elements := make([]Object, len(obj.Elements))
+		for idx, el := range obj.Elements {
+			elements[idx] = deepCopy(el)
+		}
+		return &ArrayObject{Elements: elements}
+	case *HashObject:
+		hash := NewHashObject()
+		for _, hk := range obj.Keys {
+			pair := obj.Pairs[hk]
+			hash.Set(pair.Key.(Hashable), pair.Key, deepCopy(pair.Value))
+		}
+		return hash
+	default:
+		return obj
+	}
+}
+
+// builtinPretty formats a value across multiple lines, indenting nested
+// arrays and hashes by two spaces per level (e.g., suna pretty(h);).
+func builtinPretty(tok lexer.Token, args ...Object) Object {
+	if err := checkArgs(tok, "pretty", args, 1); err != nil {
+		return err
+	}
+	var sb strings.Builder
+	writePretty(&sb, args[0], "")
+	return &StringObject{Value: sb.String()}
+}
+
+// writePretty writes obj to sb, starting nested lines with indent.
+func writePretty(sb *strings.Builder, obj Object, indent string) {
+	inner := indent + "  "
+	switch obj := obj.(type) {
+	case *ArrayObject:
+		if len(obj.Elements) == 0 {
+			sb.WriteString("[]")
+			return
+		}
+		sb.WriteString("[\n")
+		for idx, el := range obj.Elements {
+			sb.WriteString(inner)
+			writePretty(sb, el, inner)
+			if idx < len(obj.Elements)-1 {
+				sb.WriteString(",")
+			}
+			sb.WriteString("\n")
+		}
+		sb.WriteString(indent + "]")
+	case *HashObject:
+		if len(obj.Keys) == 0 {
+			sb.WriteString("{}")
+			return
+		}
+		sb.WriteString("{\n")
+		for idx, hk := range obj.Keys {
+			pair := obj.Pairs[hk]
+			sb.WriteString(inner + inspect(pair.Key) + ": ")
+			writePretty(sb, pair.Value, inner)
+			if idx < len(obj.Keys)-1 {
+				sb.WriteString(",")
+			}
+			sb.WriteString("\n")
+		}
+		sb.WriteString(indent + "}")
+	default:
+		sb.WriteString(inspect(obj))
+	}
+}
+
+// builtinBool converts any value to yas or nah by the same rules agar uses
+// (e.g., bool(0) is nah and bool("x") is yas).
+func builtinBool(tok lexer.Token, args ...Object) Object {
+	if err := checkArgs(tok, "bool", args, 1); err != nil {
+		return err
+	}
+	return nativeBoolToBoolObject(isTruthy(args[0]))
+}
+
+// builtinSame reports whether two arrays or hashes are the very same value
+// rather than equal copies (e.g., after sun b = a, same(a, b) is yas, but
+// same(a, clone(a)) is nah). Other values can't be aliased, so for them
+// same is plain equality.
+func builtinSame(tok lexer.Token, args ...Object) Object {
+	if err := checkArgs(tok, "same", args, 2); err != nil {
+		return err
+	}
+	if isCollection(args[0]) || isCollection(args[1]) {
+		return nativeBoolToBoolObject(args[0] == args[1])
+	}
+	return nativeBoolToBoolObject(objectsEqual(args[0], args[1]))
+}
+
+// builtinLooseEq compares two values after coercion, unlike the strict ==
+// (e.g., loose_eq("5", 5) is yas). The rules, in order:
+//   - ints and floats compare by numeric value, so 3 and 3.0 are equal;
+//   - a string and a number compare as numbers if the string, with any
+//     surrounding spaces removed, is a number ("2.50" equals 2.5), and are
+//     unequal otherwise;
+//   - anything else, including two strings, is equal only if both values
+//     have the same type and print the same, so arrays and hashes compare
+//     by their contents.
+//
+// Booleans are never coerced: loose_eq(yas, 1) is nah.
+func builtinLooseEq(tok lexer.Token, args ...Object) Object {
+	if err := checkArgs(tok, "loose_eq", args, 2); err != nil {
+		return err
+	}
+	_, leftIsString := args[0].(*StringObject)
+	_, rightIsString := args[1].(*StringObject)
+	left, leftIsNumber := looseNumber(args[0])
+	right, rightIsNumber := looseNumber(args[1])
+	if leftIsNumber && rightIsNumber && !(leftIsString && rightIsString) {
+		return nativeBoolToBoolObject(left == right)
+	}
+	if TypeName(args[0]) != TypeName(args[1]) {
+		return FALSE
+	}
+	return nativeBoolToBoolObject(inspect(args[0]) == inspect(args[1]))
+}
+
+// looseNumber returns the numeric value of an int, a float or a string
+// holding a number, and whether obj has one.
+func looseNumber(obj Object) (float64, bool) {
+	switch obj := obj.(type) {
+	case *IntObject:
+		return float64(obj.Value), true
+	case *FloatObject:
+		return obj.Value, true
+	case *StringObject:
+		value, err := strconv.ParseFloat(strings.TrimSpace(obj.Value), 64)
+		return value, err == nil
+	}
+	return 0, false
+}
+
+// builtinIsqrt returns the largest int whose square is at most n (e.g., isqrt(17) is 4).
+func builtinIsqrt(tok lexer.Token, args ...Object) Object {
+	if err := checkArgs(tok, "isqrt", args, 1); err != nil {
+		return err
+	}
+	n, ok := args[0].(*IntObject)
+	if !ok {
+		return newError(tok, "isqrt expects an int, got %s", TypeName(args[0]))
+	}
+	if n.Value < 0 {
+		return newError(tok, "isqrt: negative number %d", n.Value)
+	}
+	// The float estimate can be off by one for large n, so correct it.
+	// Dividing instead of squaring keeps the checks from overflowing.
+	root := int64(math.Sqrt(float64(n.Value)))
+	for root > 0 && root > n.Value/root {
+		root--
+	}
+	for root+1 <= n.Value/(root+1) {
+		root++
+	}
+	return newInt(root)
+}
+
+// builtinSqrt returns the square root of an int or float as a float (e.g., sqrt(2.25) is 1.5).
+func builtinSqrt(tok lexer.Token, args ...Object) Object {
+	if err := checkArgs(tok, "sqrt", args, 1); err != nil {
+		return err
+	}
+	var x float64
+	switch arg := args[0].(type) {
+	case *IntObject:
+		x = float64(arg.Value)
+	case *FloatObject:
+		x = arg.Value
+	default:
+		return newError(tok, "sqrt expects a number, got %s", TypeName(arg))
+	}
+	if x < 0 {
+		return newError(tok, "sqrt: negative number %s", args[0].String())
+	}
+	return &FloatObject{Value: math.Sqrt(x)}
+}
+
+// builtinDigitSum returns the sum of the decimal digits of n (e.g., digit_sum(1234) is 10).
+func builtinDigitSum(tok lexer.Token, args ...Object) Object {
+	n, err := naturalArg(tok, "digit_sum", args)
+	if err != nil {
+		return err
+	}
+	sum := int64(0)
+	for ; n > 0; n /= 10 {
+		sum += n % 10
+	}
+	return newInt(sum)
+}
+
+// builtinNumDigits returns how many decimal digits n has (e.g., num_digits(1234)
+// is 4). Zero has one digit.
+func builtinNumDigits(tok lexer.Token, args ...Object) Object {
+	n, err := naturalArg(tok, "num_digits", args)
+	if err != nil {
+		return err
+	}
+	count := int64(1)
+	for ; n >= 10; n /= 10 {
+		count++
+	}
+	return newInt(count)
+}
+
+// naturalArg returns the single non-negative int argument of the named builtin.
+func naturalArg(tok lexer.Token, name string, args []Object) (int64, *ErrorObject) {
+	if err := checkArgs(tok, name, args, 1); err != nil {
+		return 0, err
+	}
+	n, ok := args[0].(*IntObject)
+	if !ok {
+		return 0, newError(tok, "%s expects an int, got %s", name, TypeName(args[0]))
+	}
+	if n.Value < 0 {
+		return 0, newError(tok, "%s: negative number %d", name, n.Value)
+	}
+	return n.Value, nil
+}
+
+// builtinGcd returns the greatest common divisor of two ints (e.g., gcd(12, 18)
+// is 6). Signs are ignored, so the result is never negative, and gcd(n, 0) is
+// |n|, which makes gcd(0, 0) 0.
+func builtinGcd(tok lexer.Token, args ...Object) Object {
+	a, b, err := intPairArgs(tok, "gcd", args)
+	if err != nil {
+		return err
+	}
+	g := gcd(absUint(a), absUint(b))
+	if g > math.MaxInt64 {
+		return newError(tok, "gcd: result overflows an int")
+	}
+	return newInt(int64(g))
+}
+
+// builtinLcm returns the least common multiple of two ints (e.g., lcm(4, 6)
+// is 12). Signs are ignored, so the result is never negative, and it is 0 if
+// either argument is 0.
+func builtinLcm(tok lexer.Token, args ...Object) Object {
+	a, b, err := intPairArgs(tok, "lcm", args)
+	if err != nil {
+		return err
+	}
+	if a == 0 || b == 0 {
+		return newInt(0)
+	}
+	x, y := absUint(a), absUint(b)
+	x /= gcd(x, y)
+	if x > math.MaxInt64/y {
+		return newError(tok, "lcm: result overflows an int")
+	}
+	return newInt(int64(x * y))
+}
+
+// intPairArgs returns the two int arguments of the named builtin.
+func intPairArgs(tok lexer.Token, name string, args []Object) (int64, int64, *ErrorObject) {
+	if err := checkArgs(tok, name, args, 2); err != nil {
+		return 0, 0, err
+	}
+	a, ok1 := args[0].(*IntObject)
+	b, ok2 := args[1].(*IntObject)
+	if !ok1 || !ok2 {
+		return 0, 0, newError(tok, "%s expects two ints, got %s and %s", name, TypeName(args[0]), TypeName(args[1]))
+	}
+	return a.Value, b.Value, nil
+}
+
+// absUint returns |n| as a uint64, which holds it even for the most negative int.
+func absUint(n int64) uint64 {
+	if n < 0 {
+		return uint64(-(n + 1)) + 1
+	}
+	return uint64(n)
+}
+
+// gcd returns the greatest common divisor of a and b by Euclid's algorithm.
+func gcd(a, b uint64) uint64 {
+	for b != 0 {
+		a, b = b, a%b
+	}
+	return a
+}
+
+// builtinPrintf returns format with each verb replaced by the next argument
+// (e.g., printf("%d-%s", 1, "x") is "1-x"). %d takes an int, %s and %v take
+// any value as suna would print it, and %% is a literal percent sign. The
+// number of verbs must match the number of arguments.
+func builtinPrintf(tok lexer.Token, args ...Object) Object {
+	if len(args) == 0 {
+		return newError(tok, "printf expects a format string")
+	}
+	format, ok := args[0].(*StringObject)
+	if !ok {
+		return newError(tok, "printf expects a format string, got %s", TypeName(args[0]))
+	}
+	var verbs []byte
+	for idx := 0; idx < len(format.Value); idx++ {
+		if format.Value[idx] != '%' {
+			continue
+		}
+		idx++
+		if idx == len(format.Value) {
+			return newError(tok, "printf format ends with a lone %%")
+		}
+		switch verb := format.Value[idx]; verb {
+		case '%':
+		case 'd', 's', 'v':
+			verbs = append(verbs, verb)
+		default:
+			return newError(tok, "printf does not support %%%c", verb)
+		}
+	}
+	values := args[1:]
+	if len(verbs) != len(values) {
+		return newError(tok, "printf format has %d verb(s) but got %d argument(s)", len(verbs), len(values))
+	}
+	converted := make([]interface{}, len(values))
+	for idx, value := range values {
+		if verbs[idx] == 'd' {
+			n, ok := value.(*IntObject)
+			if !ok {
+				return newError(tok, "printf %%d expects an int, got %s", TypeName(value))
+			}
+			converted[idx] = n.Value
+		} else {
+			converted[idx] = value.String()
+		}
+	}
+	return &StringObject{Value: fmt.Sprintf(format.Value, converted...)}
+}
+
+// builtinFmtDuration formats a millisecond count for people (e.g.,
+// fmt_duration(83500) is "1m 23s"). Under a second it shows milliseconds;
+// otherwise leftover milliseconds are dropped and units above the largest
+// non-zero one are left out.
+func builtinFmtDuration(tok lexer.Token, args ...Object) Object {
+	if err := checkArgs(tok, "fmt_duration", args, 1); err != nil {
+		return err
+	}
+	ms, ok := args[0].(*IntObject)
+	if !ok {
+		return newError(tok, "fmt_duration expects an int, got %s", TypeName(args[0]))
+	}
+	if ms.Value < 0 {
+		return newError(tok, "fmt_duration: negative duration %d", ms.Value)
+	}
+	if ms.Value < 1000 {
+		return &StringObject{Value: fmt.Sprintf("%dms", ms.Value)}
+	}
+	secs := ms.Value / 1000
+	hours, minutes, seconds := secs/3600, secs/60%60, secs%60
+	switch {
+	case hours > 0:
+		return &StringObject{Value: fmt.Sprintf("%dh %dm %ds", hours, minutes, seconds)}
+	case minutes > 0:
+		return &StringObject{Value: fmt.Sprintf("%dm %ds", minutes, seconds)}
+	default:
+		return &StringObject{Value: fmt.Sprintf("%ds", seconds)}
+	}
+}
+
+// builtinZip pairs up the elements of two arrays by index, stopping at the
+// end of the shorter one (e.g., zip([1, 2], ["a"]) is [[1, "a"]]).
+func builtinZip(tok lexer.Token, args ...Object) Object {
+	if err := checkArgs(tok, "zip", args, 2); err != nil {
+		return err
+	}
+	a, ok1 := args[0].(*ArrayObject)
+	b, ok2 := args[1].(*ArrayObject)
+	if !ok1 || !ok2 {
+		return newError(tok, "zip expects two arrays, got %s and %s", TypeName(args[0]), TypeName(args[1]))
+	}
+	n := min(len(a.Elements), len(b.Elements))
+	pairs := make([]Object, n)
+	for idx := range n {
+		pairs[idx] = &ArrayObject{Elements: []Object{a.Elements[idx], b.Elements[idx]}}
+	}
+	return &ArrayObject{Elements: pairs}
+}
+
+// builtinEnumerate pairs each element of an array with its index
+// (e.g., enumerate(["a", "b"]) is [[0, "a"], [1, "b"]]).
+func builtinEnumerate(tok lexer.Token, args ...Object) Object {
+	if err := checkArgs(tok, "enumerate", args, 1); err != nil {
+		return err
+	}
+	arr, ok := args[0].(*ArrayObject)
+	if !ok {
+		return newError(tok, "enumerate expects an array, got %s", TypeName(args[0]))
+	}
+	pairs := make([]Object, len(arr.Elements))
+	for idx, el := range arr.Elements {
+		pairs[idx] = &ArrayObject{Elements: []Object{newInt(int64(idx)), el}}
+	}
+	return &ArrayObject{Elements: pairs}
+}
+
+// clamp limits n to the range [lo, hi].
+func clamp(n, lo, hi int64) int64 {
+	if n < lo {
+		return lo
+	}
+	if n > hi {
+		return hi
+	}
+	return n
+}
+
+// builtinType returns the name of a value's type (e.g., type(1.5) is "float").
+func builtinType(tok lexer.Token, args ...Object) Object {
+	if err := checkArgs(tok, "type", args, 1); err != nil {
+		return err
+	}
+	return &StringObject{Value: TypeName(args[0])}
+}
+
+// builtinTokens runs the lexer over a string and returns its tokens as
+// [type, literal] pairs (e.g., tokens("sun x") is [["SUN", "sun"], ["IDENT", "x"]]).
+// The EOF token is left out. Only available after EnableMetaBuiltins.
+func builtinTokens(tok lexer.Token, args ...Object) Object {
+	if err := checkArgs(tok, "tokens", args, 1); err != nil {
+		return err
+	}
+	src, ok := args[0].(*StringObject)
+	if !ok {
+		return newError(tok, "tokens expects a string, got %s", TypeName(args[0]))
+	}
+	var pairs []Object
+	l := lexer.New(src.Value)
+	for t := l.NextToken(); t.Type != lexer.EOF; t = l.NextToken() {
+		pairs = append(pairs, &ArrayObject{Elements: []Object{
+			&StringObject{Value: string(t.Type)},
+			&StringObject{Value: t.Literal},
+		}})
+	}
+	return &ArrayObject{Elements: pairs}
+}
+
+// builtinEval parses and runs a string of code in the current scope and
+// returns the value of its last statement if that is an expression, or khali
+// otherwise (e.g., eval("1 + 2") is 3). Syntax errors and runtime errors are
+// returned as errors. Only available after EnableMetaBuiltins.
+func (i *Interpreter) builtinEval(tok lexer.Token, args ...Object) Object {
+	if err := checkArgs(tok, "eval", args, 1); err != nil {
+		return err
+	}
+	src, ok := args[0].(*StringObject)
+	if !ok {
+		return newError(tok, "eval expects a string, got %s", TypeName(args[0]))
+	}
+	p := parser.New(lexer.New(src.Value), false)
+	p.Quiet = true
+	program := p.ParseProgram()
+	if errs := p.Errors(); len(errs) > 0 {
+		return newError(tok, "eval: syntax error at line %d, col %d: %s", errs[0].Line, errs[0].Column, errs[0].Message)
+	}
+	var result Object = NULL
+	for _, stmt := range program.Statements {
+		value := i.Eval(stmt)
+		if IsError(value) {
+			return value
+		}
+		result = NULL
+		if _, ok := stmt.(*parser.ExpressionStatement); ok && value != nil {
+			result = value
+		}
+	}
+	return result
+}
+
+// builtinDepth returns the number of active user function calls (e.g., depth()).
+func (i *Interpreter) builtinDepth(tok lexer.Token, args ...Object) Object {
+	if err := checkArgs(tok, "depth", args, 0); err != nil {
+		return err
+	}
+	return newInt(int64(i.callDepth))
+}
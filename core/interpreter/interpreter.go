@@ -2,12 +2,17 @@ package interpreter
 
 import (
 	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"unicode/utf8"
 
 	"github.com/salillakra/npp/frontend/lexer"
 	"github.com/salillakra/npp/frontend/parser"
 )
 
-// Object represents a value in the language (number or string).
+// Object represents a value in the language (int, float, string, boolean, array, hash, or builtin).
 type Object interface {
 	String() string
 }
@@ -17,7 +22,51 @@ type IntObject struct {
 	Value int64
 }
 
-func (i *IntObject) String() string { return fmt.Sprintf("%d", i.Value) }
+// String formats the integer in plain base 10. int64 has no negative zero,
+// so results like 0 * -1 always print as 0.
+func (i *IntObject) String() string { return strconv.FormatInt(i.Value, 10) }
+
+// Cached IntObjects for small values, reused to cut allocations in tight
+// arithmetic. Like TRUE and FALSE they are shared, so never modify them.
+const (
+	smallIntMin = -128
+	smallIntMax = 255
+)
+
+var smallInts [smallIntMax - smallIntMin + 1]*IntObject
+
+func init() {
+	for idx := range smallInts {
+		smallInts[idx] = &IntObject{Value: int64(idx + smallIntMin)}
+	}
+}
+
+// newInt returns an IntObject for v, using the shared cache for small values.
+func newInt(v int64) *IntObject {
+	if v >= smallIntMin && v <= smallIntMax {
+		return smallInts[v-smallIntMin]
+	}
+	return &IntObject{Value: v}
+}
+
+// FloatObject represents a floating-point value.
+type FloatObject struct {
+	Value float64
+}
+
+// String formats the float as the shortest representation that round-trips,
+// always keeping a decimal point so floats never look like ints: 3.0 prints
+// as "3.0", 0.1 as "0.1", 1e21 as "1e+21". Negative zero prints as "0.0".
+func (f *FloatObject) String() string {
+	if f.Value == 0 {
+		return "0.0"
+	}
+	str := strconv.FormatFloat(f.Value, 'g', -1, 64)
+	if !strings.ContainsAny(str, ".eIN") {
+		str += ".0"
+	}
+	return str
+}
 
 // StringObject represents a string value.
 type StringObject struct {
@@ -26,9 +75,189 @@ type StringObject struct {
 
 func (s *StringObject) String() string { return s.Value }
 
-// Environment stores variable bindings.
+// BoolObject represents a boolean value. It always prints as yas or nah.
+type BoolObject struct {
+	Value bool
+}
+
+func (b *BoolObject) String() string {
+	if b.Value {
+		return "yas"
+	}
+	return "nah"
+}
+
+// NullObject represents the absence of a value. It prints as khali.
+type NullObject struct{}
+
+func (n *NullObject) String() string { return "khali" }
+
+// Shared immutable values. Every boolean result is TRUE or FALSE, so code
+// must never modify them.
+var (
+	TRUE  = &BoolObject{Value: true}
+	FALSE = &BoolObject{Value: false}
+	NULL  = &NullObject{}
+)
+
+// nativeBoolToBoolObject returns the shared TRUE or FALSE object.
+func nativeBoolToBoolObject(b bool) *BoolObject {
+	if b {
+		return TRUE
+	}
+	return FALSE
+}
+
+// ArrayObject represents an ordered list of values.
+type ArrayObject struct {
+	Elements []Object
+}
+
+func (a *ArrayObject) String() string {
+	elements := make([]string, len(a.Elements))
+	for idx, el := range a.Elements {
+		elements[idx] = inspect(el)
+	}
+	return "[" + strings.Join(elements, ", ") + "]"
+}
+
+// HashKey identifies a hashable Object inside a HashObject.
+type HashKey struct {
+	Type  string
+	Value string
+}
+
+// Hashable is implemented by objects that can be used as hash keys.
+type Hashable interface {
+	HashKey() HashKey
+}
+
+func (i *IntObject) HashKey() HashKey    { return HashKey{Type: "int", Value: i.String()} }
+func (s *StringObject) HashKey() HashKey { return HashKey{Type: "string", Value: s.Value} }
+func (b *BoolObject) HashKey() HashKey   { return HashKey{Type: "bool", Value: b.String()} }
+
+// HashPair holds an original key together with its value.
+type HashPair struct {
+	Key   Object
+	Value Object
+}
+
+// HashObject represents a hash of key/value pairs. Keys remembers insertion order.
+type HashObject struct {
+	Pairs map[HashKey]HashPair
+	Keys  []HashKey
+}
+
+// NewHashObject creates an empty hash.
+func NewHashObject() *HashObject {
+	return &HashObject{Pairs: make(map[HashKey]HashPair)}
+}
+
+// Set stores value under key, keeping the original position of existing keys.
+func (h *HashObject) Set(key Hashable, k, value Object) {
+	hk := key.HashKey()
+	if _, ok := h.Pairs[hk]; !ok {
+		h.Keys = append(h.Keys, hk)
+	}
+	h.Pairs[hk] = HashPair{Key: k, Value: value}
+}
+
+func (h *HashObject) String() string {
+	pairs := make([]string, len(h.Keys))
+	for idx, hk := range h.Keys {
+		pair := h.Pairs[hk]
+		pairs[idx] = fmt.Sprintf("%s: %s", inspect(pair.Key), inspect(pair.Value))
+	}
+	return "{" + strings.Join(pairs, ", ") + "}"
+}
+
+// ErrorObject represents a runtime error. It halts execution when it reaches
+// the top level unless a koshish block catches it first.
+type ErrorObject struct {
+	Message string
+	Line    int
+	Column  int
+	caught  bool // set once bound by pakad, so the value no longer propagates
+}
+
+func (e *ErrorObject) String() string {
+	return fmt.Sprintf("Error at line %d, col %d: %s", e.Line, e.Column, e.Message)
+}
+
+// newError creates an ErrorObject positioned at tok.
+func newError(tok lexer.Token, format string, args ...interface{}) *ErrorObject {
+	return &ErrorObject{Message: fmt.Sprintf(format, args...), Line: tok.Line, Column: tok.Column}
+}
+
+// IsError reports whether obj is an ErrorObject that is still propagating.
+func IsError(obj Object) bool {
+	err, ok := obj.(*ErrorObject)
+	return ok && !err.caught
+}
+
+// BuiltinFunc is the Go implementation of a builtin. tok is the call site, used for error positions.
+type BuiltinFunc func(tok lexer.Token, args ...Object) Object
+
+// BuiltinObject represents a builtin function value.
+type BuiltinObject struct {
+	Name string
+	Fn   BuiltinFunc
+}
+
+func (b *BuiltinObject) String() string { return fmt.Sprintf("builtin %s", b.Name) }
+
+// FunctionObject represents a function declared with glow. Env is the scope
+// it was declared in, so its body can see the variables around it.
+type FunctionObject struct {
+	Name       string
+	Parameters []*parser.Identifier
+	Body       *parser.BlockStatement
+	Env        *Environment
+}
+
+func (f *FunctionObject) String() string { return fmt.Sprintf("glow %s", f.Name) }
+
+// TypeName returns the name of obj's type as reported by the type() builtin.
+func TypeName(obj Object) string {
+	switch obj.(type) {
+	case *IntObject:
+		return "int"
+	case *FloatObject:
+		return "float"
+	case *StringObject:
+		return "string"
+	case *BoolObject:
+		return "bool"
+	case *NullObject:
+		return "null"
+	case *ArrayObject:
+		return "array"
+	case *HashObject:
+		return "hash"
+	case *ErrorObject:
+		return "error"
+	case *BuiltinObject:
+		return "builtin"
+	case *FunctionObject:
+		return "function"
+	default:
+		return "unknown"
+	}
+}
+
+// inspect formats an Object nested inside a collection, quoting strings.
+func inspect(obj Object) string {
+	if str, ok := obj.(*StringObject); ok {
+		return fmt.Sprintf("%q", str.Value)
+	}
+	return obj.String()
+}
+
+// Environment stores variable bindings. A nested scope points at its outer scope.
 type Environment struct {
 	store map[string]Object
+	names []string // names in declaration order, since map iteration is random
+	outer *Environment
 }
 
 // NewEnvironment creates a new environment.
@@ -36,102 +265,477 @@ func NewEnvironment() *Environment {
 	return &Environment{store: make(map[string]Object)}
 }
 
+// NewEnclosedEnvironment creates a scope nested inside outer.
+func NewEnclosedEnvironment(outer *Environment) *Environment {
+	env := NewEnvironment()
+	env.outer = outer
+	return env
+}
+
+// Get looks a name up in this scope and then in the enclosing ones.
+func (e *Environment) Get(name string) (Object, bool) {
+	value, ok := e.store[name]
+	if !ok && e.outer != nil {
+		return e.outer.Get(name)
+	}
+	return value, ok
+}
+
+// Set updates name in the nearest scope that already has it, or defines it
+// in this scope if none does.
+func (e *Environment) Set(name string, value Object) {
+	for env := e; env != nil; env = env.outer {
+		if _, ok := env.store[name]; ok {
+			env.store[name] = value
+			return
+		}
+	}
+	e.Define(name, value)
+}
+
+// Define binds name in this scope only, shadowing any outer binding.
+func (e *Environment) Define(name string, value Object) {
+	if _, ok := e.store[name]; !ok {
+		e.names = append(e.names, name)
+	}
+	e.store[name] = value
+}
+
+// Vars returns the names bound in this scope in the order they were first declared.
+func (e *Environment) Vars() []string {
+	return append([]string(nil), e.names...)
+}
+
 // Interpreter evaluates the AST.
 type Interpreter struct {
-	env *Environment
+	// Out receives everything the program prints. nil means os.Stdout.
+	Out io.Writer
+
+	// IntWidth selects integer semantics: 32 makes int literals and
+	// arithmetic wrap like a 32-bit two's complement integer. Any other
+	// value, including the zero default, means 64-bit.
+	IntWidth int
+
+	// OnStatement, if set, is called before each statement is evaluated,
+	// including statements nested in blocks. Debuggers and coverage tools
+	// hook in here.
+	OnStatement func(stmt parser.Statement)
+
+	env       *Environment
+	builtins  map[string]*BuiltinObject
+	callDepth int              // number of active user function calls
+	captured  *strings.Builder // non-nil once CaptureOutput is called
 }
 
 // New creates a new Interpreter with optional sassy comments.
 func New() *Interpreter {
-	return &Interpreter{
-		env: NewEnvironment(),
+	i := &Interpreter{
+		env:      NewEnvironment(),
+		builtins: make(map[string]*BuiltinObject, len(builtins)+1),
+	}
+	for name, builtin := range builtins {
+		i.builtins[name] = builtin
+	}
+	i.builtins["depth"] = &BuiltinObject{Name: "depth", Fn: i.builtinDepth}
+	return i
+}
+
+// NewWithBuiltins creates an Interpreter whose only builtins are fns, keyed
+// by the name scripts call them by. None of the standard builtins are
+// available, so a host can hand untrusted scripts a curated set.
+func NewWithBuiltins(fns map[string]BuiltinFunc) *Interpreter {
+	i := &Interpreter{
+		env:      NewEnvironment(),
+		builtins: make(map[string]*BuiltinObject, len(fns)),
+	}
+	for name, fn := range fns {
+		i.builtins[name] = &BuiltinObject{Name: name, Fn: fn}
+	}
+	return i
+}
+
+// EnableMetaBuiltins adds the builtins that let scripts inspect and run
+// code, tokens and eval. They are off by default since few scripts need them
+// and eval can do anything the script itself could.
+func (i *Interpreter) EnableMetaBuiltins() {
+	i.builtins["tokens"] = &BuiltinObject{Name: "tokens", Fn: builtinTokens}
+	i.builtins["eval"] = &BuiltinObject{Name: "eval", Fn: i.builtinEval}
+}
+
+// DisableBuiltins makes every call to the named builtins fail with an error,
+// e.g. to keep untrusted scripts away from the host. Names the interpreter
+// doesn't have are ignored, so one blocklist works across builtin sets.
+func (i *Interpreter) DisableBuiltins(names ...string) {
+	for _, name := range names {
+		if _, ok := i.builtins[name]; !ok {
+			continue
+		}
+		i.builtins[name] = &BuiltinObject{Name: name, Fn: func(tok lexer.Token, args ...Object) Object {
+			return newError(tok, "%s is disabled in this interpreter", name)
+		}}
 	}
 }
 
-// Interpret executes the program.
+// CaptureOutput redirects everything the program prints into an internal
+// buffer instead of Out. Use Output to read it, e.g. in a web playground.
+func (i *Interpreter) CaptureOutput() {
+	if i.captured == nil {
+		i.captured = &strings.Builder{}
+	}
+}
+
+// Output returns everything printed since CaptureOutput was called.
+func (i *Interpreter) Output() string {
+	if i.captured == nil {
+		return ""
+	}
+	return i.captured.String()
+}
+
+// writer returns where program output currently goes.
+func (i *Interpreter) writer() io.Writer {
+	if i.captured != nil {
+		return i.captured
+	}
+	if i.Out != nil {
+		return i.Out
+	}
+	return os.Stdout
+}
+
+// flush pushes buffered output through when Out is buffered (e.g., a
+// *bufio.Writer), so interactive programs show each suna immediately.
+func (i *Interpreter) flush() {
+	if f, ok := i.writer().(interface{ Flush() error }); ok {
+		f.Flush()
+	}
+}
+
+// Interpret executes the program. An uncaught error is printed and halts execution.
 func (i *Interpreter) Interpret(program *parser.Program) {
 	if program == nil || program.Statements == nil {
 		return
 	}
 	for _, stmt := range program.Statements {
 		if stmt != nil {
-			i.evalStatement(stmt)
+			if result := i.evalStatement(stmt); IsError(result) {
+				fmt.Fprintln(i.writer(), result.String())
+				i.flush()
+				return
+			}
 		}
 	}
 }
 
-// evalStatement evaluates a statement.
-func (i *Interpreter) evalStatement(stmt parser.Statement) {
+// Vars returns the global variable names in declaration order.
+func (i *Interpreter) Vars() []string {
+	return i.globals().Vars()
+}
+
+// Lookup returns the value bound to name, if any.
+func (i *Interpreter) Lookup(name string) (Object, bool) {
+	return i.env.Get(name)
+}
+
+// globals returns the outermost environment.
+func (i *Interpreter) globals() *Environment {
+	env := i.env
+	for env.outer != nil {
+		env = env.outer
+	}
+	return env
+}
+
+// Eval executes a single statement. For an expression statement it returns
+// the expression's value; otherwise it returns nil or an *ErrorObject.
+func (i *Interpreter) Eval(stmt parser.Statement) Object {
+	if es, ok := stmt.(*parser.ExpressionStatement); ok {
+		if i.OnStatement != nil {
+			i.OnStatement(stmt)
+		}
+		return i.evalExpression(es.Expression)
+	}
+	return i.evalStatement(stmt)
+}
+
+// evalStatement evaluates a statement. It returns an *ErrorObject when
+// execution must stop, a *loopSignal for tod and agla, a *returnSignal for
+// fhek, and nil otherwise.
+func (i *Interpreter) evalStatement(stmt parser.Statement) Object {
 	if stmt == nil {
-		return // Skip nil statements
+		return nil // Skip nil statements
+	}
+	if i.OnStatement != nil {
+		i.OnStatement(stmt)
 	}
 	switch s := stmt.(type) {
 	case *parser.PrintStatement:
-		if s == nil || s.Value == nil {
+		if s == nil || len(s.Values) == 0 {
 			if s != nil {
-				fmt.Printf("Error at line %d, col %d: Invalid print statement \n",
+				fmt.Fprintf(i.writer(), "Error at line %d, col %d: Invalid print statement \n",
 					s.Token().Line, s.Token().Column)
 			}
-			return
+			return nil
 		}
-		value := i.evalExpression(s.Value)
-		if value != nil {
-			fmt.Println(value.String())
-		} else {
-			fmt.Printf("Error at line %d, col %d: Invalid expression in print \n",
-				s.Token().Line, s.Token().Column)
+		// Evaluate every value before printing so an error prints nothing.
+		values := make([]string, len(s.Values))
+		for idx, expr := range s.Values {
+			value := i.evalExpression(expr)
+			if IsError(value) {
+				return value
+			}
+			if value == nil {
+				fmt.Fprintf(i.writer(), "Error at line %d, col %d: Invalid expression in print \n",
+					s.Token().Line, s.Token().Column)
+				return nil
+			}
+			values[idx] = value.String()
 		}
+		fmt.Fprintln(i.writer(), strings.Join(values, " "))
+		i.flush()
 	case *parser.AssignmentStatement:
 		if s == nil || s.Name == nil || s.Value == nil {
 			if s != nil {
-				fmt.Printf("Error at line %d, col %d: Invalid assignment statement \n",
+				fmt.Fprintf(i.writer(), "Error at line %d, col %d: Invalid assignment statement \n",
 					s.Token().Line, s.Token().Column)
 			}
-			return
+			return nil
 		}
 		value := i.evalExpression(s.Value)
-		if value != nil {
-			i.env.store[s.Name.Value] = value
+		if IsError(value) {
+			return value
+		}
+		if value != nil && s.Names != nil {
+			return i.unpack(s, value)
+		} else if value != nil {
+			i.env.Set(s.Name.Value, value)
 		} else {
-			fmt.Printf("Error at line %d, col %d: Invalid expression in assignment \n",
+			fmt.Fprintf(i.writer(), "Error at line %d, col %d: Invalid expression in assignment \n",
 				s.Token().Line, s.Token().Column)
 		}
+	case *parser.ExpressionStatement:
+		if value := i.evalExpression(s.Expression); IsError(value) {
+			return value
+		}
 	case *parser.IfStatement:
 		if s == nil || s.Condition == nil {
 			if s != nil {
-				fmt.Printf("Error at line %d, col %d: Invalid if statement \n",
+				fmt.Fprintf(i.writer(), "Error at line %d, col %d: Invalid if statement \n",
 					s.Token().Line, s.Token().Column)
 			}
-			return
+			return nil
 		}
 		if s.Consequence == nil {
-			fmt.Printf("Error at line %d, col %d: Invalid if block \n",
+			fmt.Fprintf(i.writer(), "Error at line %d, col %d: Invalid if block \n",
 				s.Token().Line, s.Token().Column)
-			return
+			return nil
 		}
 		condition := i.evalExpression(s.Condition)
+		if IsError(condition) {
+			return condition
+		}
 		if condition == nil {
-			fmt.Printf("Error at line %d, col %d: Invalid condition in if \n",
+			fmt.Fprintf(i.writer(), "Error at line %d, col %d: Invalid condition in if \n",
 				s.Token().Line, s.Token().Column)
-			return
+			return nil
 		}
 		if isTruthy(condition) {
-			for _, stmt := range s.Consequence.Statements {
-				if stmt != nil {
-					i.evalStatement(stmt)
-				}
-			}
-		} else if s.Alternative != nil && !isTruthy(condition) {
-			for _, stmt := range s.Alternative.Statements {
-				if stmt != nil {
-					i.evalStatement(stmt)
-				}
+			return i.evalBlock(s.Consequence)
+		} else if s.Alternative != nil {
+			return i.evalBlock(s.Alternative)
+		}
+	case *parser.WhileStatement:
+		return i.evalWhileStatement(s)
+	case *parser.BlockStatement:
+		// Names first declared in a bare block stay inside it; sun on an
+		// existing outer name still updates that name.
+		outer := i.env
+		i.env = NewEnclosedEnvironment(outer)
+		result := i.evalBlock(s)
+		i.env = outer
+		return result
+	case *parser.BranchStatement:
+		return &loopSignal{brk: s.Tok.Type == lexer.TOD, label: s.Label}
+	case *parser.PassStatement:
+		// chhod does nothing.
+	case *parser.FunctionStatement:
+		i.env.Set(s.Name.Value, &FunctionObject{Name: s.Name.Value, Parameters: s.Parameters, Body: s.Body, Env: i.env})
+	case *parser.ReturnStatement:
+		value := Object(NULL)
+		if s.Value != nil {
+			value = i.evalExpression(s.Value)
+			if value == nil || IsError(value) {
+				return value
 			}
 		}
+		return &returnSignal{value: value}
+	case *parser.TryStatement:
+		return i.evalTryStatement(s)
 	default:
 		// Handle cases where we can't get token info
-		fmt.Printf("Error: Unknown statement type\n")
+		fmt.Fprintf(i.writer(), "Error: Unknown statement type\n")
+	}
+	return nil
+}
+
+// unpack assigns the elements of an array to the names of a destructuring
+// assignment (e.g., sun k, v = entries(h)[0]). The array must have exactly one
+// element per name; nothing is assigned otherwise.
+func (i *Interpreter) unpack(s *parser.AssignmentStatement, value Object) Object {
+	array, ok := value.(*ArrayObject)
+	if !ok {
+		return newError(s.Tok, "Cannot unpack %s into %d names", TypeName(value), len(s.Names))
+	}
+	if len(array.Elements) != len(s.Names) {
+		return newError(s.Tok, "Cannot unpack %d value(s) into %d names", len(array.Elements), len(s.Names))
+	}
+	for idx, name := range s.Names {
+		i.env.Set(name.Value, array.Elements[idx])
+	}
+	return nil
+}
+
+// evalTryStatement runs the koshish block and, if it raises an error, runs the
+// pakad block in a new scope with the error bound to the catch parameter.
+// The aakhir block always runs last; an error raised there replaces the
+// outcome of the koshish/pakad blocks.
+func (i *Interpreter) evalTryStatement(s *parser.TryStatement) Object {
+	result := i.evalBlock(s.Body)
+	if IsError(result) && s.Handler != nil {
+		caught := *result.(*ErrorObject)
+		caught.caught = true
+		outer := i.env
+		i.env = NewEnclosedEnvironment(outer)
+		i.env.Define(s.Param.Value, &caught)
+		result = i.evalBlock(s.Handler)
+		i.env = outer
 	}
+	if s.Finally != nil {
+		if finallyResult := i.evalBlock(s.Finally); finallyResult != nil {
+			return finallyResult
+		}
+	}
+	return result
+}
+
+// loopSignal is returned by tod and agla. Like an error, it stops every
+// enclosing block until it reaches the loop it targets: the innermost loop,
+// or the one with the matching label.
+type loopSignal struct {
+	brk   bool // tod (break) rather than agla (continue)
+	label string
+}
+
+func (s *loopSignal) String() string {
+	if s.brk {
+		return "tod"
+	}
+	return "agla"
+}
+
+// returnSignal is returned by fhek. Like a loopSignal, it stops every
+// enclosing block until it reaches the function call it belongs to.
+type returnSignal struct {
+	value Object
+}
+
+func (s *returnSignal) String() string { return "fhek " + s.value.String() }
+
+// maxCallDepth bounds recursion so a runaway function reports an error
+// instead of overflowing the Go stack.
+const maxCallDepth = 10000
+
+// callFunction runs fn's body in a new scope, enclosed by the one fn was
+// declared in, with args bound to its parameters. It returns the value given
+// to fhek, or khali if the body ends without one.
+func (i *Interpreter) callFunction(tok lexer.Token, fn *FunctionObject, args []Object) Object {
+	if len(args) != len(fn.Parameters) {
+		return newError(tok, "%s expects %d argument(s), got %d", fn.Name, len(fn.Parameters), len(args))
+	}
+	if i.callDepth >= maxCallDepth {
+		return newError(tok, "Maximum call depth of %d exceeded in %s", maxCallDepth, fn.Name)
+	}
+	outer := i.env
+	i.env = NewEnclosedEnvironment(fn.Env)
+	for idx, param := range fn.Parameters {
+		i.env.Define(param.Value, args[idx])
+	}
+	i.callDepth++
+	result := i.evalBlock(fn.Body)
+	i.callDepth--
+	i.env = outer
+	switch signal := result.(type) {
+	case *returnSignal:
+		return signal.value
+	case *loopSignal:
+		// The parser keeps tod and agla inside the function's own loops, so
+		// this only happens with a hand-built AST. Don't let it leak out as
+		// a value or break a loop around the call.
+		return newError(tok, "%s used outside of a loop in %s", signal, fn.Name)
+	}
+	if result != nil {
+		return result
+	}
+	return NULL
+}
+
+// evalWhileStatement runs the body for as long as the condition is truthy,
+// or, for baar, as many times as the count evaluated once up front says.
+func (i *Interpreter) evalWhileStatement(s *parser.WhileStatement) Object {
+	var remaining int64
+	if s.Count != nil {
+		count := i.evalExpression(s.Count)
+		if count == nil || IsError(count) {
+			return count
+		}
+		n, ok := count.(*IntObject)
+		if !ok {
+			return newError(s.Tok, "baar expects an int count, got %s", TypeName(count))
+		}
+		if n.Value < 0 {
+			return newError(s.Tok, "baar count cannot be negative, got %d", n.Value)
+		}
+		remaining = n.Value
+	}
+	for {
+		if s.Count != nil {
+			if remaining == 0 {
+				return nil
+			}
+			remaining--
+		} else {
+			condition := i.evalExpression(s.Condition)
+			if condition == nil || IsError(condition) {
+				return condition
+			}
+			if !isTruthy(condition) {
+				return nil
+			}
+		}
+		result := i.evalBlock(s.Body)
+		if signal, ok := result.(*loopSignal); ok && (signal.label == "" || signal.label == s.Label) {
+			if signal.brk {
+				return nil
+			}
+			continue
+		}
+		if result != nil {
+			return result
+		}
+	}
+}
+
+// evalBlock evaluates the statements of a block, stopping at the first error
+// or signal from tod, agla or fhek.
+func (i *Interpreter) evalBlock(block *parser.BlockStatement) Object {
+	for _, stmt := range block.Statements {
+		if stmt != nil {
+			if result := i.evalStatement(stmt); result != nil {
+				return result
+			}
+		}
+	}
+	return nil
 }
 
 // evalExpression evaluates an expression and returns an Object.
@@ -141,27 +745,111 @@ func (i *Interpreter) evalExpression(expr parser.Expression) Object {
 	}
 	switch e := expr.(type) {
 	case *parser.NumberLiteral:
-		return &IntObject{Value: e.Value}
+		return i.newInt(e.Value)
+	case *parser.FloatLiteral:
+		return &FloatObject{Value: e.Value}
 	case *parser.StringLiteral:
 		return &StringObject{Value: e.Value}
+	case *parser.BooleanLiteral:
+		return nativeBoolToBoolObject(e.Value)
+	case *parser.NullLiteral:
+		return NULL
 	case *parser.Identifier:
-		value, ok := i.env.store[e.Value]
+		value, ok := i.env.Get(e.Value)
 		if !ok {
-			fmt.Printf("Error at line %d, col %d: Undefined variable %s \n",
-				e.Token.Line, e.Token.Column, e.Value)
-			return nil
+			if builtin, ok := i.builtins[e.Value]; ok {
+				return builtin
+			}
+			return newError(e.Token, "Undefined variable %s", e.Value)
 		}
 		return value
+	case *parser.PrefixExpression:
+		right := i.evalExpression(e.Right)
+		if right == nil || IsError(right) {
+			return right
+		}
+		if e.Operator == "!" {
+			return nativeBoolToBoolObject(!isTruthy(right))
+		}
+		switch r := right.(type) {
+		case *IntObject:
+			return i.newInt(-r.Value)
+		case *FloatObject:
+			return &FloatObject{Value: -r.Value}
+		}
+		return newError(e.Token, "Cannot negate %s %s", TypeName(right), right.String())
+	case *parser.ConditionalExpression:
+		// Only the chosen branch runs, so the other may fail harmlessly.
+		condition := i.evalExpression(e.Condition)
+		if condition == nil || IsError(condition) {
+			return condition
+		}
+		if isTruthy(condition) {
+			return i.evalExpression(e.Consequence)
+		}
+		return i.evalExpression(e.Alternative)
 	case *parser.BinaryExpression:
+		if e.Operator == "&&" || e.Operator == "||" {
+			return i.evalLogicalExpression(e)
+		}
 		left := i.evalExpression(e.Left)
-		if left == nil {
-			return nil
+		if left == nil || IsError(left) {
+			return left
 		}
 		right := i.evalExpression(e.Right)
-		if right == nil {
-			return nil
+		if right == nil || IsError(right) {
+			return right
 		}
 		return i.evalBinaryExpression(e.Token, left, e.Operator, right)
+	case *parser.ArrayLiteral:
+		elements := make([]Object, 0, len(e.Elements))
+		for _, elExpr := range e.Elements {
+			el := i.evalExpression(elExpr)
+			if el == nil || IsError(el) {
+				return el
+			}
+			elements = append(elements, el)
+		}
+		return &ArrayObject{Elements: elements}
+	case *parser.HashLiteral:
+		return i.evalHashLiteral(e)
+	case *parser.CallExpression:
+		function := i.evalExpression(e.Function)
+		if function == nil || IsError(function) {
+			return function
+		}
+		args := make([]Object, 0, len(e.Arguments))
+		for _, argExpr := range e.Arguments {
+			arg := i.evalExpression(argExpr)
+			if arg == nil || IsError(arg) {
+				return arg
+			}
+			args = append(args, arg)
+		}
+		if fn, ok := function.(*FunctionObject); ok {
+			return i.callFunction(e.Token, fn, args)
+		}
+		builtin, ok := function.(*BuiltinObject)
+		if !ok {
+			return newError(e.Token, "%s is not a function", function.String())
+		}
+		return builtin.Fn(e.Token, args...)
+	case *parser.IndexExpression:
+		left := i.evalExpression(e.Left)
+		if left == nil || IsError(left) {
+			return left
+		}
+		if e.Safe && left == NULL {
+			return NULL // The index isn't evaluated, as with the right side of &&.
+		}
+		index := i.evalExpression(e.Index)
+		if index == nil || IsError(index) {
+			return index
+		}
+		if e.Safe && indexMissing(left, index) {
+			return NULL
+		}
+		return i.evalIndexExpression(e.Token, left, index)
 	default:
 		// Try to get token info if possible, else use -1
 		line, col := -1, -1
@@ -169,76 +857,372 @@ func (i *Interpreter) evalExpression(expr parser.Expression) Object {
 			tok := tokExpr.Token()
 			line, col = tok.Line, tok.Column
 		}
-		fmt.Printf("Error at line %d, col %d: Unknown expression type \n",
+		fmt.Fprintf(i.writer(), "Error at line %d, col %d: Unknown expression type \n",
 			line, col)
 		return nil
 	}
 }
 
+// evalHashLiteral evaluates a hash literal, rejecting unhashable keys.
+func (i *Interpreter) evalHashLiteral(hl *parser.HashLiteral) Object {
+	hash := NewHashObject()
+	for idx, keyExpr := range hl.Keys {
+		key := i.evalExpression(keyExpr)
+		if key == nil || IsError(key) {
+			return key
+		}
+		hashable, ok := key.(Hashable)
+		if !ok {
+			return newError(hl.Token, "Unusable as hash key: %s", key.String())
+		}
+		value := i.evalExpression(hl.Values[idx])
+		if value == nil || IsError(value) {
+			return value
+		}
+		hash.Set(hashable, key, value)
+	}
+	return hash
+}
+
+// evalIndexExpression evaluates an index access. Missing hash keys and
+// out-of-range array indices are errors.
+func (i *Interpreter) evalIndexExpression(token lexer.Token, left, index Object) Object {
+	if array, ok := left.(*ArrayObject); ok {
+		idx, ok := index.(*IntObject)
+		if !ok {
+			return newError(token, "Array index must be an int, got %s", index.String())
+		}
+		if err := checkIndex(token, idx.Value, len(array.Elements)); err != nil {
+			return err
+		}
+		return array.Elements[idx.Value]
+	}
+	if str, ok := left.(*StringObject); ok {
+		idx, ok := index.(*IntObject)
+		if !ok {
+			return newError(token, "String index must be an int, got %s", index.String())
+		}
+		// Like len and substr, strings are indexed by character, not byte.
+		runes := []rune(str.Value)
+		if err := checkIndex(token, idx.Value, len(runes)); err != nil {
+			return err
+		}
+		return &StringObject{Value: string(runes[idx.Value])}
+	}
+	hash, ok := left.(*HashObject)
+	if !ok {
+		return newError(token, "Index operator not supported on %s", left.String())
+	}
+	hashable, ok := index.(Hashable)
+	if !ok {
+		return newError(token, "Unusable as hash key: %s", index.String())
+	}
+	pair, ok := hash.Pairs[hashable.HashKey()]
+	if !ok {
+		return newError(token, "Key %s not found", inspect(index))
+	}
+	return pair.Value
+}
+
+// indexMissing reports whether left[index] is well-typed but names nothing: an
+// int index out of range of an array or string, or a key a hash lacks. Other
+// misuse, like indexing an int, is still an error under a?[i].
+func indexMissing(left, index Object) bool {
+	switch l := left.(type) {
+	case *ArrayObject:
+		idx, ok := index.(*IntObject)
+		return ok && (idx.Value < 0 || idx.Value >= int64(len(l.Elements)))
+	case *StringObject:
+		idx, ok := index.(*IntObject)
+		return ok && (idx.Value < 0 || idx.Value >= int64(utf8.RuneCountInString(l.Value)))
+	case *HashObject:
+		hashable, ok := index.(Hashable)
+		if !ok {
+			return false
+		}
+		_, found := l.Pairs[hashable.HashKey()]
+		return !found
+	}
+	return false
+}
+
+// newInt returns an IntObject for v, wrapped to 32 bits when IntWidth is 32.
+func (i *Interpreter) newInt(v int64) *IntObject {
+	if i.IntWidth == 32 {
+		v = int64(int32(v))
+	}
+	return newInt(v)
+}
+
+// checkIndex returns an error naming the valid range if idx is not a valid
+// index into a sequence of the given length.
+func checkIndex(token lexer.Token, idx int64, length int) *ErrorObject {
+	if idx >= 0 && idx < int64(length) {
+		return nil
+	}
+	if length == 0 {
+		return newError(token, "Index %d out of range for length 0 (it is empty)", idx)
+	}
+	return newError(token, "Index %d out of range for length %d (valid: 0 to %d)", idx, length, length-1)
+}
+
+// evalLogicalExpression evaluates && and || by truthiness, skipping the right
+// operand when the left one already decides the result.
+func (i *Interpreter) evalLogicalExpression(e *parser.BinaryExpression) Object {
+	left := i.evalExpression(e.Left)
+	if left == nil || IsError(left) {
+		return left
+	}
+	if isTruthy(left) == (e.Operator == "||") {
+		return nativeBoolToBoolObject(isTruthy(left))
+	}
+	right := i.evalExpression(e.Right)
+	if right == nil || IsError(right) {
+		return right
+	}
+	return nativeBoolToBoolObject(isTruthy(right))
+}
+
 // evalBinaryExpression evaluates a binary expression (arithmetic or comparison).
 func (i *Interpreter) evalBinaryExpression(token lexer.Token, left Object, op string, right Object) Object {
-	// Handle arithmetic (int + int)
+	// Handle arithmetic (int + int). Like Go, / truncates toward zero and %
+	// takes the sign of the dividend: -7 / 2 is -3 and -7 % 2 is -1.
 	if leftInt, ok1 := left.(*IntObject); ok1 {
 		if rightInt, ok2 := right.(*IntObject); ok2 {
 			switch op {
 			case "+":
-				return &IntObject{Value: leftInt.Value + rightInt.Value}
+				return i.newInt(leftInt.Value + rightInt.Value)
 			case "-":
-				return &IntObject{Value: leftInt.Value - rightInt.Value}
+				return i.newInt(leftInt.Value - rightInt.Value)
 			case "*":
-				return &IntObject{Value: leftInt.Value * rightInt.Value}
+				return i.newInt(leftInt.Value * rightInt.Value)
 			case "%":
-				return &IntObject{Value: leftInt.Value % rightInt.Value}
+				if rightInt.Value == 0 {
+					return newError(token, "Modulo by zero")
+				}
+				return i.newInt(leftInt.Value % rightInt.Value)
 			case "/":
 				if rightInt.Value == 0 {
-					fmt.Printf("Error at line %d, col %d: Division by zero \n",
-						token.Line, token.Column)
-					return nil
+					return newError(token, "Division by zero")
 				}
-				return &IntObject{Value: leftInt.Value / rightInt.Value}
+				return i.newInt(leftInt.Value / rightInt.Value)
 			case "==":
-				return &IntObject{Value: boolToInt(leftInt.Value == rightInt.Value)}
+				return nativeBoolToBoolObject(leftInt.Value == rightInt.Value)
 			case "!=":
-				return &IntObject{Value: boolToInt(leftInt.Value != rightInt.Value)}
+				return nativeBoolToBoolObject(leftInt.Value != rightInt.Value)
 			case "<":
-				return &IntObject{Value: boolToInt(leftInt.Value < rightInt.Value)}
+				return nativeBoolToBoolObject(leftInt.Value < rightInt.Value)
 			case ">":
-				return &IntObject{Value: boolToInt(leftInt.Value > rightInt.Value)}
+				return nativeBoolToBoolObject(leftInt.Value > rightInt.Value)
 			case "<=":
-				return &IntObject{Value: boolToInt(leftInt.Value <= rightInt.Value)}
+				return nativeBoolToBoolObject(leftInt.Value <= rightInt.Value)
 			case ">=":
-				return &IntObject{Value: boolToInt(leftInt.Value >= rightInt.Value)}
+				return nativeBoolToBoolObject(leftInt.Value >= rightInt.Value)
 			}
 		}
 	}
-	// Handle string + string (concatenation)
+	// Mixing an int with a float promotes the int, so 3 == 3.0 and 1 + 0.5 is 1.5.
+	// Errors below still show the operands as written.
+	promotedLeft, promotedRight := promoteMixed(left, right)
+	// Handle arithmetic (float + float)
+	if leftFloat, ok1 := promotedLeft.(*FloatObject); ok1 {
+		if rightFloat, ok2 := promotedRight.(*FloatObject); ok2 {
+			switch op {
+			case "+":
+				return &FloatObject{Value: leftFloat.Value + rightFloat.Value}
+			case "-":
+				return &FloatObject{Value: leftFloat.Value - rightFloat.Value}
+			case "*":
+				return &FloatObject{Value: leftFloat.Value * rightFloat.Value}
+			case "/":
+				// An error, as for ints, rather than Inf or NaN. -0.0 == 0 too.
+				if rightFloat.Value == 0 {
+					return newError(token, "Division by zero")
+				}
+				return &FloatObject{Value: leftFloat.Value / rightFloat.Value}
+			case "==":
+				return nativeBoolToBoolObject(leftFloat.Value == rightFloat.Value)
+			case "!=":
+				return nativeBoolToBoolObject(leftFloat.Value != rightFloat.Value)
+			case "<":
+				return nativeBoolToBoolObject(leftFloat.Value < rightFloat.Value)
+			case ">":
+				return nativeBoolToBoolObject(leftFloat.Value > rightFloat.Value)
+			case "<=":
+				return nativeBoolToBoolObject(leftFloat.Value <= rightFloat.Value)
+			case ">=":
+				return nativeBoolToBoolObject(leftFloat.Value >= rightFloat.Value)
+			}
+		}
+	}
+	// Handle string + string (concatenation) and string comparisons, which
+	// order byte by byte: "apple" < "banana" and "Z" < "a".
 	if leftStr, ok1 := left.(*StringObject); ok1 {
 		if rightStr, ok2 := right.(*StringObject); ok2 {
-			if op == "+" {
+			switch op {
+			case "+":
 				return &StringObject{Value: leftStr.Value + rightStr.Value}
+			case "==":
+				return nativeBoolToBoolObject(leftStr.Value == rightStr.Value)
+			case "!=":
+				return nativeBoolToBoolObject(leftStr.Value != rightStr.Value)
+			case "<":
+				return nativeBoolToBoolObject(leftStr.Value < rightStr.Value)
+			case ">":
+				return nativeBoolToBoolObject(leftStr.Value > rightStr.Value)
+			case "<=":
+				return nativeBoolToBoolObject(leftStr.Value <= rightStr.Value)
+			case ">=":
+				return nativeBoolToBoolObject(leftStr.Value >= rightStr.Value)
 			}
 		}
 	}
-	fmt.Printf("Error at line %d, col %d: Invalid operation %s between %s and %s \n",
-		token.Line, token.Column, op, left.String(), right.String())
-	return nil
+	// Handle bool == bool and bool != bool
+	if leftBool, ok1 := left.(*BoolObject); ok1 {
+		if rightBool, ok2 := right.(*BoolObject); ok2 {
+			switch op {
+			case "==":
+				return nativeBoolToBoolObject(leftBool.Value == rightBool.Value)
+			case "!=":
+				return nativeBoolToBoolObject(leftBool.Value != rightBool.Value)
+			}
+		}
+	}
+	// khali equals only khali, so any value can be checked against it.
+	if (left == NULL || right == NULL) && (op == "==" || op == "!=") {
+		return nativeBoolToBoolObject((left == right) == (op == "=="))
+	}
+	// Arrays and hashes compare by contents; see same() for identity.
+	if isCollection(left) && isCollection(right) && (op == "==" || op == "!=") {
+		return nativeBoolToBoolObject(objectsEqual(left, right) == (op == "=="))
+	}
+	// Booleans are never coerced to 0/1 in arithmetic; name the offending operand instead.
+	if isArithmeticOperator(op) {
+		if _, ok := left.(*BoolObject); ok {
+			return newError(token, "Cannot use boolean %s as the left operand of %s", left.String(), op)
+		}
+		if _, ok := right.(*BoolObject); ok {
+			return newError(token, "Cannot use boolean %s as the right operand of %s", right.String(), op)
+		}
+	}
+	// Ordering values of different types, like "10" < 5, is almost always a
+	// missing conversion, so say so rather than reporting a generic failure.
+	if isOrderingOperator(op) && TypeName(left) != TypeName(right) {
+		return newError(token, "Cannot order %s and %s with %s", TypeName(left), TypeName(right), op)
+	}
+	return newError(token, "Invalid operation %s between %s and %s", op, left.String(), right.String())
 }
 
-// boolToInt converts a boolean to 1 (true) or 0 (false).
-func boolToInt(b bool) int64 {
-	if b {
-		return 1
+// promoteMixed converts the int operand to a float when the other operand is
+// a float. Any other pair is returned unchanged.
+func promoteMixed(left, right Object) (Object, Object) {
+	switch l := left.(type) {
+	case *IntObject:
+		if _, ok := right.(*FloatObject); ok {
+			return &FloatObject{Value: float64(l.Value)}, right
+		}
+	case *FloatObject:
+		if r, ok := right.(*IntObject); ok {
+			return left, &FloatObject{Value: float64(r.Value)}
+		}
 	}
-	return 0
+	return left, right
+}
+
+// isCollection reports whether obj is an array or a hash.
+func isCollection(obj Object) bool {
+	switch obj.(type) {
+	case *ArrayObject, *HashObject:
+		return true
+	}
+	return false
+}
+
+// objectsEqual reports whether a and b hold the same value. Arrays are equal
+// when their elements are equal in order, hashes when they have the same keys
+// with equal values in any order. Values of different types are unequal,
+// except that ints and floats compare numerically.
+func objectsEqual(a, b Object) bool {
+	a, b = promoteMixed(a, b)
+	switch a := a.(type) {
+	case *IntObject:
+		b, ok := b.(*IntObject)
+		return ok && a.Value == b.Value
+	case *FloatObject:
+		b, ok := b.(*FloatObject)
+		return ok && a.Value == b.Value
+	case *StringObject:
+		b, ok := b.(*StringObject)
+		return ok && a.Value == b.Value
+	case *BoolObject:
+		b, ok := b.(*BoolObject)
+		return ok && a.Value == b.Value
+	case *NullObject:
+		_, ok := b.(*NullObject)
+		return ok
+	case *ArrayObject:
+		b, ok := b.(*ArrayObject)
+		if !ok || len(a.Elements) != len(b.Elements) {
+			return false
+		}
+		for idx := range a.Elements {
+			if !objectsEqual(a.Elements[idx], b.Elements[idx]) {
+				return false
+			}
+		}
+		return true
+	case *HashObject:
+		b, ok := b.(*HashObject)
+		if !ok || len(a.Keys) != len(b.Keys) {
+			return false
+		}
+		for hk, pair := range a.Pairs {
+			other, ok := b.Pairs[hk]
+			if !ok || !objectsEqual(pair.Value, other.Value) {
+				return false
+			}
+		}
+		return true
+	}
+	return false
+}
+
+// isOrderingOperator reports whether op is <, >, <= or >=.
+func isOrderingOperator(op string) bool {
+	switch op {
+	case "<", ">", "<=", ">=":
+		return true
+	}
+	return false
+}
+
+// isArithmeticOperator reports whether op is +, -, *, / or %.
+func isArithmeticOperator(op string) bool {
+	switch op {
+	case "+", "-", "*", "/", "%":
+		return true
+	}
+	return false
 }
 
 // isTruthy determines if an Object is truthy for conditionals.
+// Booleans are taken as-is, numbers are truthy when non-zero (so legacy 1/0
+// conditions keep working), strings, arrays and hashes when non-empty.
+// Anything else, such as khali, is falsy.
 func isTruthy(obj Object) bool {
 	switch o := obj.(type) {
+	case *BoolObject:
+		return o.Value
 	case *IntObject:
 		return o.Value != 0
+	case *FloatObject:
+		return o.Value != 0
 	case *StringObject:
 		return len(o.Value) > 0
+	case *ArrayObject:
+		return len(o.Elements) > 0
+	case *HashObject:
+		return len(o.Keys) > 0
 	default:
 		return false
 	}
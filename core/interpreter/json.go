@@ -0,0 +1,172 @@
+package interpreter
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/salillakra/npp/frontend/lexer"
+)
+
+// builtinToJSON serializes a value to a JSON string (e.g., to_json({"a": [1, 2]})).
+// Hash keys must be strings and are written in sorted order; khali becomes null.
+func builtinToJSON(tok lexer.Token, args ...Object) Object {
+	if err := checkArgs(tok, "to_json", args, 1); err != nil {
+		return err
+	}
+	var sb strings.Builder
+	if err := writeJSON(&sb, args[0]); err != nil {
+		return newError(tok, "to_json: %s", err)
+	}
+	return &StringObject{Value: sb.String()}
+}
+
+// writeJSON writes obj to sb as JSON.
+func writeJSON(sb *strings.Builder, obj Object) error {
+	switch obj := obj.(type) {
+	case *IntObject:
+		sb.WriteString(obj.String())
+	case *FloatObject:
+		if math.IsNaN(obj.Value) || math.IsInf(obj.Value, 0) {
+			return fmt.Errorf("%s has no JSON form", obj.String())
+		}
+		sb.WriteString(obj.String()) // keeps ".0" so the value reads back as a float
+	case *StringObject:
+		writeJSONString(sb, obj.Value)
+	case *BoolObject:
+		sb.WriteString(strconv.FormatBool(obj.Value))
+	case *NullObject:
+		sb.WriteString("null")
+	case *ArrayObject:
+		sb.WriteByte('[')
+		for idx, el := range obj.Elements {
+			if idx > 0 {
+				sb.WriteByte(',')
+			}
+			if err := writeJSON(sb, el); err != nil {
+				return err
+			}
+		}
+		sb.WriteByte(']')
+	case *HashObject:
+		keys := make([]string, 0, len(obj.Keys))
+		for _, hk := range obj.Keys {
+			pair := obj.Pairs[hk]
+			key, ok := pair.Key.(*StringObject)
+			if !ok {
+				return fmt.Errorf("hash keys must be strings, got %s %s", TypeName(pair.Key), pair.Key.String())
+			}
+			keys = append(keys, key.Value)
+		}
+		// Sorted keys keep the output stable and diff-friendly.
+		sort.Strings(keys)
+		sb.WriteByte('{')
+		for idx, key := range keys {
+			if idx > 0 {
+				sb.WriteByte(',')
+			}
+			writeJSONString(sb, key)
+			sb.WriteByte(':')
+			if err := writeJSON(sb, obj.Pairs[HashKey{Type: "string", Value: key}].Value); err != nil {
+				return err
+			}
+		}
+		sb.WriteByte('}')
+	default:
+		return fmt.Errorf("%s values have no JSON form", TypeName(obj))
+	}
+	return nil
+}
+
+// writeJSONString writes s as a quoted, escaped JSON string.
+func writeJSONString(sb *strings.Builder, s string) {
+	quoted, _ := json.Marshal(s) // marshaling a string cannot fail
+	sb.Write(quoted)
+}
+
+// builtinFromJSON parses a JSON string into npp values (e.g., from_json("[1, 2.5]")).
+// Objects become hashes in document order, numbers with a fraction or
+// exponent become floats and null becomes khali.
+func builtinFromJSON(tok lexer.Token, args ...Object) Object {
+	if err := checkArgs(tok, "from_json", args, 1); err != nil {
+		return err
+	}
+	str, ok := args[0].(*StringObject)
+	if !ok {
+		return newError(tok, "from_json expects a string, got %s", TypeName(args[0]))
+	}
+	dec := json.NewDecoder(strings.NewReader(str.Value))
+	dec.UseNumber()
+	value, err := readJSON(dec)
+	if err == nil {
+		if _, extra := dec.Token(); extra != io.EOF {
+			err = errors.New("unexpected data after the top-level value")
+		}
+	}
+	if err != nil {
+		return newError(tok, "from_json: invalid JSON: %s", err)
+	}
+	return value
+}
+
+// readJSON reads the next JSON value from dec. Objects are read token by
+// token so their keys keep the order they have in the document.
+func readJSON(dec *json.Decoder) (Object, error) {
+	t, err := dec.Token()
+	if err != nil {
+		if err == io.EOF {
+			err = io.ErrUnexpectedEOF
+		}
+		return nil, err
+	}
+	switch t := t.(type) {
+	case json.Delim:
+		if t == '[' {
+			array := &ArrayObject{Elements: []Object{}}
+			for dec.More() {
+				el, err := readJSON(dec)
+				if err != nil {
+					return nil, err
+				}
+				array.Elements = append(array.Elements, el)
+			}
+			_, err := dec.Token() // Closing ']'
+			return array, err
+		}
+		hash := NewHashObject()
+		for dec.More() {
+			k, err := dec.Token()
+			if err != nil {
+				return nil, err
+			}
+			key := &StringObject{Value: k.(string)}
+			value, err := readJSON(dec)
+			if err != nil {
+				return nil, err
+			}
+			hash.Set(key, key, value)
+		}
+		_, err := dec.Token() // Closing '}'
+		return hash, err
+	case json.Number:
+		if n, err := strconv.ParseInt(t.String(), 10, 64); err == nil {
+			return newInt(n), nil
+		}
+		f, err := t.Float64()
+		if err != nil {
+			return nil, err
+		}
+		return &FloatObject{Value: f}, nil
+	case string:
+		return &StringObject{Value: t}, nil
+	case bool:
+		return nativeBoolToBoolObject(t), nil
+	default: // nil
+		return NULL, nil
+	}
+}
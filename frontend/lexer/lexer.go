@@ -1,6 +1,10 @@
 package lexer
 
 import (
+	"io"
+	"sort"
+	"strconv"
+	"strings"
 	"unicode"
 )
 
@@ -11,15 +15,35 @@ type Lexer struct {
 	ch           byte   // current char
 	line         int    // current line number (1-based)
 	column       int    // current column number (1-based)
+
+	// AttachComments makes the lexer keep the "//" comment lines directly
+	// above a token in its LeadingComment field instead of discarding them.
+	AttachComments bool
 }
 
-// New creates a new Lexer instance for the given input string.
+// New creates a new Lexer instance for the given input string. A "#!"
+// shebang on the first line is skipped so scripts can be made executable.
 func New(input string) *Lexer {
 	l := &Lexer{input: input, line: 1, column: 1}
 	l.readChar()
+	if l.ch == '#' && l.peekChar() == '!' {
+		for l.ch != '\n' && l.ch != 0 {
+			l.readChar()
+		}
+	}
 	return l
 }
 
+// NewReader creates a new Lexer that takes its input from r. The reader is
+// buffered up front, so the tokens match those of New over the same source.
+func NewReader(r io.Reader) (*Lexer, error) {
+	var sb strings.Builder
+	if _, err := io.Copy(&sb, r); err != nil {
+		return nil, err
+	}
+	return New(sb.String()), nil
+}
+
 // readChar advances the lexer to the next character.
 func (l *Lexer) readChar() {
 	if l.readPosition >= len(l.input) {
@@ -46,6 +70,9 @@ type Token struct {
 	Literal string    // Literal value (e.g., "69", "x")
 	Line    int       // Line number (1-based)
 	Column  int       // Column number (1-based)
+
+	LeadingComment string // Comment lines above the token, if AttachComments is set
+	AfterNewline   bool   // A line break separates the token from the one before it
 }
 
 // Token types
@@ -56,6 +83,7 @@ const (
 	// Identifiers and literals
 	IDENT  = "IDENT"  // x, y, jerk
 	INT    = "INT"    // 123
+	FLOAT  = "FLOAT"  // 3.14
 	STRING = "STRING" // "you suck"
 
 	// Operators
@@ -67,10 +95,15 @@ const (
 	BANG     = "!"
 	ASTERISK = "*"
 	SLASH    = "/"
+	PERCENT  = "%"
 	LT       = "<"
 	GT       = ">"
 	LE       = "<="
 	GE       = ">="
+	AND      = "&&"
+	OR       = "||"
+	QUESTION = "?"
+	SAFE_IDX = "?[" // safe index, as in a?[i]
 
 	// Punctuation
 	COMMA     = ","
@@ -79,24 +112,43 @@ const (
 	RPAREN    = ")"
 	LBRACE    = "{"
 	RBRACE    = "}"
+	LBRACKET  = "["
+	RBRACKET  = "]"
+	COLON     = ":"
 
 	// Keywords
-	SUN   = "SUN"   // sun (variable declaration)
-	SUNA  = "SUNA"  // suna (print)
-	AGAR  = "AGAR"  // agar (if)
-	MAGAR = "MAGAR" // magar (else)
-	GLOW  = "GLOW"  // glow (function)
-	FHEK  = "FHEK"  // fhek (return)
-	YAS   = "YAS"   // yas (true)
-	NAH   = "NAH"   // nah (false)
-	GRIND = "GRIND" // grind (while)
+	SUN     = "SUN"     // sun (variable declaration)
+	SUNA    = "SUNA"    // suna (print)
+	AGAR    = "AGAR"    // agar (if)
+	MAGAR   = "MAGAR"   // magar (else)
+	GLOW    = "GLOW"    // glow (function)
+	FHEK    = "FHEK"    // fhek (return)
+	YAS     = "YAS"     // yas (true)
+	NAH     = "NAH"     // nah (false)
+	KHALI   = "KHALI"   // khali (null)
+	GRIND   = "GRIND"   // grind (while)
+	KOSHISH = "KOSHISH" // koshish (try)
+	PAKAD   = "PAKAD"   // pakad (catch)
+	AAKHIR  = "AAKHIR"  // aakhir (finally)
+	TOD     = "TOD"     // tod (break)
+	AGLA    = "AGLA"    // agla (continue)
+	BAAR    = "BAAR"    // baar (repeat N times)
+	CHHOD   = "CHHOD"   // chhod (do nothing)
 )
 
 // NextToken returns the next token from the input.
 func (l *Lexer) NextToken() Token {
-	l.skipWhitespace()
-	l.skipComment()
+	comment, newline := l.skipWhitespaceAndComments()
+	tok := l.readToken()
+	tok.LeadingComment = comment
+	tok.AfterNewline = newline
+	return tok
+}
 
+// readToken reads the token starting at the current character.
+func (l *Lexer) readToken() Token {
+	// Position of the token's first character; two-character operators keep
+	// it after reading their second character.
 	tok := Token{Line: l.line, Column: l.column}
 
 	switch l.ch {
@@ -104,7 +156,7 @@ func (l *Lexer) NextToken() Token {
 		if l.peekChar() == '=' {
 			ch := l.ch
 			l.readChar()
-			tok = Token{Type: EQ, Literal: string(ch) + string(l.ch), Line: l.line, Column: l.column}
+			tok = Token{Type: EQ, Literal: string(ch) + string(l.ch), Line: tok.Line, Column: tok.Column}
 		} else {
 			tok = newToken(ASSIGN, string(l.ch), l.line, l.column)
 		}
@@ -112,7 +164,7 @@ func (l *Lexer) NextToken() Token {
 		if l.peekChar() == '=' {
 			ch := l.ch
 			l.readChar()
-			tok = Token{Type: NOT_EQ, Literal: string(ch) + string(l.ch), Line: l.line, Column: l.column}
+			tok = Token{Type: NOT_EQ, Literal: string(ch) + string(l.ch), Line: tok.Line, Column: tok.Column}
 		} else {
 			tok = newToken(BANG, string(l.ch), l.line, l.column)
 		}
@@ -122,18 +174,15 @@ func (l *Lexer) NextToken() Token {
 		tok = newToken(MINUS, string(l.ch), l.line, l.column)
 	case '*':
 		tok = newToken(ASTERISK, string(l.ch), l.line, l.column)
+	case '%':
+		tok = newToken(PERCENT, string(l.ch), l.line, l.column)
 	case '/':
-		if l.peekChar() == '/' {
-			l.readChar() // Skip first '/'
-			l.skipComment()
-			return l.NextToken() // Recursively get next token after comment
-		}
 		tok = newToken(SLASH, string(l.ch), l.line, l.column)
 	case '<':
 		if l.peekChar() == '=' {
 			ch := l.ch
 			l.readChar()
-			tok = Token{Type: LE, Literal: string(ch) + string(l.ch), Line: l.line, Column: l.column}
+			tok = Token{Type: LE, Literal: string(ch) + string(l.ch), Line: tok.Line, Column: tok.Column}
 		} else {
 			tok = newToken(LT, string(l.ch), l.line, l.column)
 		}
@@ -141,10 +190,24 @@ func (l *Lexer) NextToken() Token {
 		if l.peekChar() == '=' {
 			ch := l.ch
 			l.readChar()
-			tok = Token{Type: GE, Literal: string(ch) + string(l.ch), Line: l.line, Column: l.column}
+			tok = Token{Type: GE, Literal: string(ch) + string(l.ch), Line: tok.Line, Column: tok.Column}
 		} else {
 			tok = newToken(GT, string(l.ch), l.line, l.column)
 		}
+	case '&':
+		if l.peekChar() == '&' {
+			l.readChar()
+			tok = Token{Type: AND, Literal: "&&", Line: tok.Line, Column: tok.Column}
+		} else {
+			tok = newToken(ILLEGAL, string(l.ch), l.line, l.column)
+		}
+	case '|':
+		if l.peekChar() == '|' {
+			l.readChar()
+			tok = Token{Type: OR, Literal: "||", Line: tok.Line, Column: tok.Column}
+		} else {
+			tok = newToken(ILLEGAL, string(l.ch), l.line, l.column)
+		}
 	case ',':
 		tok = newToken(COMMA, string(l.ch), l.line, l.column)
 	case ';':
@@ -157,9 +220,42 @@ func (l *Lexer) NextToken() Token {
 		tok = newToken(LBRACE, string(l.ch), l.line, l.column)
 	case '}':
 		tok = newToken(RBRACE, string(l.ch), l.line, l.column)
+	case '[':
+		tok = newToken(LBRACKET, string(l.ch), l.line, l.column)
+	case ']':
+		tok = newToken(RBRACKET, string(l.ch), l.line, l.column)
+	case ':':
+		tok = newToken(COLON, string(l.ch), l.line, l.column)
+	case '?':
+		if l.peekChar() == '[' {
+			ch := l.ch
+			l.readChar()
+			tok = Token{Type: SAFE_IDX, Literal: string(ch) + string(l.ch), Line: tok.Line, Column: tok.Column}
+		} else {
+			tok = newToken(QUESTION, string(l.ch), l.line, l.column)
+		}
 	case '"':
 		tok.Type = STRING
-		tok.Literal = l.readString()
+		if strings.HasPrefix(l.input[l.position:], `"""`) {
+			var ok bool
+			if tok.Literal, ok = l.readTripleString(); !ok {
+				tok.Type = ILLEGAL
+			}
+		} else {
+			var ok bool
+			if tok.Literal, ok = l.readString(); !ok {
+				tok.Type = ILLEGAL
+			}
+		}
+		tok.Line = l.line
+		tok.Column = l.column
+		return tok
+	case '`':
+		tok.Type = STRING
+		var ok bool
+		if tok.Literal, ok = l.readRawString(); !ok {
+			tok.Type = ILLEGAL
+		}
 		tok.Line = l.line
 		tok.Column = l.column
 		return tok
@@ -175,8 +271,7 @@ func (l *Lexer) NextToken() Token {
 			tok.Column = l.column
 			return tok
 		} else if isDigit(l.ch) {
-			tok.Type = INT
-			tok.Literal = l.readNumber()
+			tok.Literal, tok.Type = l.readNumber()
 			tok.Line = l.line
 			tok.Column = l.column
 			return tok
@@ -194,20 +289,67 @@ func newToken(tokenType TokenType, literal string, line, column int) Token {
 	return Token{Type: tokenType, Literal: literal, Line: line, Column: column}
 }
 
-// skipWhitespace skips over whitespace characters.
-func (l *Lexer) skipWhitespace() {
-	for l.ch == ' ' || l.ch == '\t' || l.ch == '\n' || l.ch == '\r' {
-		l.readChar()
+// skipWhitespaceAndComments skips whitespace and "//" comments and reports
+// whether it crossed a line break. With AttachComments set it also returns
+// the comment lines that sit on their own lines directly above the next
+// token; a blank line or code in between drops them.
+func (l *Lexer) skipWhitespaceAndComments() (string, bool) {
+	var lines []string
+	sawNewline := false
+	for {
+		newlines := 0
+		for l.ch == ' ' || l.ch == '\t' || l.ch == '\n' || l.ch == '\r' {
+			if l.ch == '\n' {
+				newlines++
+			}
+			l.readChar()
+		}
+		if newlines > 0 {
+			sawNewline = true
+		}
+		if newlines > 1 {
+			lines = nil
+		}
+		if l.ch != '/' || l.peekChar() != '/' {
+			return strings.Join(lines, "\n"), sawNewline
+		}
+		ownLine := l.atLineStart()
+		text := l.readComment()
+		if !l.AttachComments {
+			continue
+		}
+		if ownLine {
+			lines = append(lines, text)
+		} else {
+			lines = nil
+		}
 	}
 }
 
-// skipComment skips single-line comments starting with "//".
-func (l *Lexer) skipComment() {
-	if l.ch == '/' && l.peekChar() == '/' {
-		for l.ch != '\n' && l.ch != 0 {
-			l.readChar()
+// atLineStart reports whether only spaces or tabs precede the current
+// character on its line.
+func (l *Lexer) atLineStart() bool {
+	for i := l.position - 1; i >= 0 && l.input[i] != '\n'; i-- {
+		if l.input[i] != ' ' && l.input[i] != '\t' {
+			return false
 		}
 	}
+	return true
+}
+
+// readComment reads a "//" comment up to the end of the line and returns its
+// text without the slashes and a single following space.
+func (l *Lexer) readComment() string {
+	l.readChar() // Skip first '/'
+	l.readChar() // Skip second '/'
+	if l.ch == ' ' {
+		l.readChar()
+	}
+	start := l.position
+	for l.ch != '\n' && l.ch != 0 {
+		l.readChar()
+	}
+	return strings.TrimRight(l.input[start:l.position], "\r")
 }
 
 // readIdentifier reads an identifier or keyword.
@@ -219,28 +361,112 @@ func (l *Lexer) readIdentifier() string {
 	return l.input[start:l.position]
 }
 
-// readNumber reads an integer literal.
-func (l *Lexer) readNumber() string {
+// readNumber reads an integer or float literal, including any "_" digit
+// separators; the parser checks where they are placed. A '.' only starts a
+// fraction when a digit or separator follows it.
+func (l *Lexer) readNumber() (string, TokenType) {
 	start := l.position
-	for isDigit(l.ch) {
+	for isDigit(l.ch) || l.ch == '_' {
 		l.readChar()
 	}
-	return l.input[start:l.position]
+	if l.ch == '.' && (isDigit(l.peekChar()) || l.peekChar() == '_') {
+		l.readChar() // Skip '.'
+		for isDigit(l.ch) || l.ch == '_' {
+			l.readChar()
+		}
+		return l.input[start:l.position], FLOAT
+	}
+	return l.input[start:l.position], INT
 }
 
-// readString reads a string literal enclosed in quotes.
-func (l *Lexer) readString() string {
+// readString reads a string literal enclosed in quotes, decoding the
+// escapes \n, \t, \r, \", \\ and \xHH (the byte with hex value HH). Other
+// backslashes are kept as written. It reports false if a \x escape is not
+// followed by two hex digits.
+func (l *Lexer) readString() (string, bool) {
 	l.readChar() // Skip opening quote
-	start := l.position
+	var sb strings.Builder
+	ok := true
 	for l.ch != '"' && l.ch != 0 {
+		if l.ch == '\\' {
+			if decoded, known := escapes[l.peekChar()]; known {
+				l.readChar()
+				sb.WriteByte(decoded)
+				l.readChar()
+				continue
+			}
+			if l.peekChar() == 'x' {
+				l.readChar() // Skip the backslash
+				digits := l.input[l.readPosition:min(l.readPosition+2, len(l.input))]
+				value, err := strconv.ParseUint(digits, 16, 8)
+				if len(digits) != 2 || err != nil {
+					// Keep lexing to the closing quote so one bad escape
+					// doesn't swallow the rest of the line.
+					ok = false
+					l.readChar() // Skip the x
+					continue
+				}
+				sb.WriteByte(byte(value))
+				l.readChar() // Skip the x
+				l.readChar()
+				l.readChar()
+				continue
+			}
+		}
+		sb.WriteByte(l.ch)
 		l.readChar()
 	}
 	if l.ch == 0 {
-		return l.input[start:l.position] // Unterminated string
+		return sb.String(), ok // Unterminated string
 	}
-	str := l.input[start:l.position]
 	l.readChar() // Skip closing quote
-	return str
+	return sb.String(), ok
+}
+
+// escapes maps the character after a backslash to the byte it stands for.
+var escapes = map[byte]byte{
+	'n':  '\n',
+	't':  '\t',
+	'r':  '\r',
+	'"':  '"',
+	'\\': '\\',
+}
+
+// readRawString reads a `-delimited string, which may span lines and keeps
+// backslashes as written. It reports false if the input ends before the
+// closing backtick.
+func (l *Lexer) readRawString() (string, bool) {
+	l.readChar() // Skip opening backtick
+	start := l.position
+	for l.ch != '`' && l.ch != 0 {
+		l.readChar()
+	}
+	if l.ch == 0 {
+		return l.input[start:l.position], false
+	}
+	str := l.input[start:l.position]
+	l.readChar() // Skip closing backtick
+	return str, true
+}
+
+// readTripleString reads a """-delimited string, which may span lines. It
+// reports false if the input ends before the closing quotes.
+func (l *Lexer) readTripleString() (string, bool) {
+	for range 3 {
+		l.readChar() // Skip opening quotes
+	}
+	start := l.position
+	end := strings.Index(l.input[start:], `"""`)
+	if end < 0 {
+		for l.ch != 0 {
+			l.readChar()
+		}
+		return l.input[start:], false
+	}
+	for l.position < start+end+3 {
+		l.readChar() // Consume the string and its closing quotes
+	}
+	return l.input[start : start+end], true
 }
 
 // peekChar returns the next character without advancing the lexer.
@@ -261,19 +487,74 @@ func isDigit(ch byte) bool {
 	return unicode.IsDigit(rune(ch))
 }
 
+// Offset converts a 1-based line and column (counted in bytes) into a byte
+// offset in input. It reports false if the position lies outside the input;
+// the column just past the end of a line is valid and maps to its newline.
+func Offset(input string, line, column int) (int, bool) {
+	if line < 1 || column < 1 {
+		return 0, false
+	}
+	start := 0
+	for l := 1; l < line; l++ {
+		nl := strings.IndexByte(input[start:], '\n')
+		if nl < 0 {
+			return 0, false
+		}
+		start += nl + 1
+	}
+	end := len(input)
+	if nl := strings.IndexByte(input[start:], '\n'); nl >= 0 {
+		end = start + nl
+	}
+	if offset := start + column - 1; offset <= end {
+		return offset, true
+	}
+	return 0, false
+}
+
+// Position converts a byte offset in input into a 1-based line and column.
+// Offsets past the end are clamped to the end of the input.
+func Position(input string, offset int) (line, column int) {
+	offset = max(0, min(offset, len(input)))
+	before := input[:offset]
+	line = strings.Count(before, "\n") + 1
+	column = offset - strings.LastIndexByte(before, '\n')
+	return line, column
+}
+
+// keywords maps reserved words to their token types.
+var keywords = map[string]TokenType{
+	"sun":     SUN,
+	"suna":    SUNA,
+	"agar":    AGAR,
+	"magar":   MAGAR,
+	"glow":    GLOW,
+	"fhek":    FHEK,
+	"yas":     YAS,
+	"nah":     NAH,
+	"khali":   KHALI,
+	"grind":   GRIND,
+	"koshish": KOSHISH,
+	"pakad":   PAKAD,
+	"aakhir":  AAKHIR,
+	"tod":     TOD,
+	"agla":    AGLA,
+	"baar":    BAAR,
+	"chhod":   CHHOD,
+}
+
+// Keywords returns all reserved words in sorted order.
+func Keywords() []string {
+	words := make([]string, 0, len(keywords))
+	for word := range keywords {
+		words = append(words, word)
+	}
+	sort.Strings(words)
+	return words
+}
+
 // lookupIdent maps identifiers to keyword token types.
 func lookupIdent(ident string) TokenType {
-	keywords := map[string]TokenType{
-		"sun":   SUN,
-		"suna":  SUNA,
-		"agar":  AGAR,
-		"magar": MAGAR,
-		"glow":  GLOW,
-		"fhek":  FHEK,
-		"yas":   YAS,
-		"nah":   NAH,
-		"grind": GRIND,
-	}
 	if tok, ok := keywords[ident]; ok {
 		return tok
 	}
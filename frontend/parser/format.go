@@ -0,0 +1,293 @@
+package parser
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/salillakra/npp/frontend/lexer"
+)
+
+// Format parses src and prints it back in a standard layout: one statement
+// per line, blocks indented by four spaces, and a ; after every statement
+// that doesn't end with a closing brace. Literals are kept as written and
+// expressions get only the parentheses they need. A function declaration,
+// and a statement with a comment above it, is set off by a blank line.
+//
+// The "//" comment lines directly above a statement, and those that end the
+// file, are printed again in the same place. Other comments, such as one at
+// the end of a line, inside an expression, before a closing brace, or split
+// from the next statement by a blank line, are dropped.
+//
+// If src has syntax errors nothing is formatted and the errors are returned.
+func Format(src string) (string, []ParseError) {
+	l := lexer.New(src)
+	l.AttachComments = true
+	p := New(l, false)
+	p.Quiet = true
+	p.KeepConstants = true
+	program := p.ParseProgram()
+	if errs := p.Errors(); len(errs) > 0 {
+		return "", errs
+	}
+	f := &formatter{}
+	if strings.HasPrefix(src, "#!") {
+		shebang, _, _ := strings.Cut(src, "\n")
+		f.out.WriteString(strings.TrimRight(shebang, "\r") + "\n")
+	}
+	f.statements(program.Statements)
+	f.comment(p.curToken.LeadingComment) // Comments above EOF end the file.
+	return f.out.String(), nil
+}
+
+// Binding levels of the expressions that precedences doesn't cover. A child
+// that binds looser than its position needs is put in parentheses.
+const (
+	conditionalLevel = LOWEST - 1  // a ? b : c
+	prefixLevel      = PRODUCT + 1 // -x, !x and negative literals
+	postfixLevel     = PRODUCT + 2 // operands, calls and index accesses
+)
+
+// formatter builds the output of Format.
+type formatter struct {
+	out   strings.Builder
+	depth int // number of enclosing blocks
+}
+
+// statements prints each statement on its own line at the current depth.
+func (f *formatter) statements(stmts []Statement) {
+	for idx, stmt := range stmts {
+		if idx > 0 && (stmt.Token().LeadingComment != "" || isFunction(stmt) || isFunction(stmts[idx-1])) {
+			f.out.WriteString("\n")
+		}
+		f.comment(stmt.Token().LeadingComment)
+		f.indent()
+		f.statement(stmt)
+		switch stmt.(type) {
+		case *IfStatement, *WhileStatement, *TryStatement, *BlockStatement, *FunctionStatement:
+			// These end with a closing brace.
+		default:
+			f.out.WriteString(";")
+		}
+		f.out.WriteString("\n")
+	}
+}
+
+func isFunction(stmt Statement) bool {
+	_, ok := stmt.(*FunctionStatement)
+	return ok
+}
+
+// comment prints text, as LeadingComment holds it, as "//" lines.
+func (f *formatter) comment(text string) {
+	if text == "" {
+		return
+	}
+	for _, line := range strings.Split(text, "\n") {
+		f.indent()
+		if line == "" {
+			f.out.WriteString("//\n")
+		} else {
+			f.out.WriteString("// " + line + "\n")
+		}
+	}
+}
+
+func (f *formatter) indent() {
+	f.out.WriteString(strings.Repeat("    ", f.depth))
+}
+
+// statement prints stmt without its indentation, comment or separator.
+func (f *formatter) statement(stmt Statement) {
+	switch s := stmt.(type) {
+	case *PrintStatement:
+		f.out.WriteString("suna " + f.list(s.Values))
+	case *AssignmentStatement:
+		names := s.Names
+		if names == nil {
+			names = []*Identifier{s.Name}
+		}
+		values := make([]string, len(names))
+		for idx, name := range names {
+			values[idx] = name.Value
+		}
+		f.out.WriteString("sun " + strings.Join(values, ", ") + " = " + f.expression(s.Value, conditionalLevel))
+	case *ExpressionStatement:
+		f.out.WriteString(f.expression(s.Expression, conditionalLevel))
+	case *IfStatement:
+		f.out.WriteString("agar " + f.expression(s.Condition, conditionalLevel) + " ")
+		f.block(s.Consequence)
+		if s.Alternative == nil {
+			return
+		}
+		f.out.WriteString(" magar ")
+		if s.Alternative.Tok.Type == lexer.AGAR {
+			// magar agar: the block holds just the chained if.
+			f.statement(s.Alternative.Statements[0])
+			return
+		}
+		f.block(s.Alternative)
+	case *TryStatement:
+		f.out.WriteString("koshish ")
+		f.block(s.Body)
+		if s.Handler != nil {
+			f.out.WriteString(" pakad (" + s.Param.Value + ") ")
+			f.block(s.Handler)
+		}
+		if s.Finally != nil {
+			f.out.WriteString(" aakhir ")
+			f.block(s.Finally)
+		}
+	case *WhileStatement:
+		if s.Label != "" {
+			f.out.WriteString(s.Label + ": ")
+		}
+		if s.Count != nil {
+			f.out.WriteString("baar " + f.expression(s.Count, conditionalLevel) + " ")
+		} else {
+			f.out.WriteString("grind " + f.expression(s.Condition, conditionalLevel) + " ")
+		}
+		f.block(s.Body)
+	case *FunctionStatement:
+		params := make([]string, len(s.Parameters))
+		for idx, param := range s.Parameters {
+			params[idx] = param.Value
+		}
+		f.out.WriteString("glow " + s.Name.Value + "(" + strings.Join(params, ", ") + ") ")
+		f.block(s.Body)
+	case *ReturnStatement:
+		f.out.WriteString("fhek")
+		if values, ok := s.Value.(*ArrayLiteral); ok && values.Token.Type == lexer.COMMA {
+			f.out.WriteString(" " + f.list(values.Elements))
+		} else if s.Value != nil {
+			f.out.WriteString(" " + f.expression(s.Value, conditionalLevel))
+		}
+	case *BlockStatement:
+		f.block(s)
+	default:
+		// BranchStatement and PassStatement print as written.
+		f.out.WriteString(stmt.String())
+	}
+}
+
+// block prints a braced block, its statements one level deeper.
+func (f *formatter) block(block *BlockStatement) {
+	if len(block.Statements) == 0 {
+		f.out.WriteString("{}")
+		return
+	}
+	f.out.WriteString("{\n")
+	f.depth++
+	f.statements(block.Statements)
+	f.depth--
+	f.indent()
+	f.out.WriteString("}")
+}
+
+// list returns the comma-separated source of exprs.
+func (f *formatter) list(exprs []Expression) string {
+	parts := make([]string, len(exprs))
+	for idx, expr := range exprs {
+		parts[idx] = f.expression(expr, conditionalLevel)
+	}
+	return strings.Join(parts, ", ")
+}
+
+// expression returns the source of expr, in parentheses if it binds looser
+// than level, the binding its position needs.
+func (f *formatter) expression(expr Expression, level int) string {
+	var out string
+	own := postfixLevel
+	switch e := expr.(type) {
+	case *Identifier:
+		out = e.Value
+	case *NumberLiteral:
+		out = e.Token.Literal
+		if e.Token.Type != lexer.INT {
+			// A negative literal; its token is the minus sign.
+			out, own = strconv.FormatInt(e.Value, 10), prefixLevel
+		}
+	case *FloatLiteral:
+		out = e.Token.Literal
+		if e.Token.Type != lexer.FLOAT {
+			out, own = strconv.FormatFloat(e.Value, 'f', -1, 64), prefixLevel
+			if !strings.Contains(out, ".") {
+				out += ".0" // Still a float when read back.
+			}
+		}
+	case *StringLiteral:
+		out = quote(e.Value)
+	case *BooleanLiteral:
+		out = e.Token.Literal
+	case *NullLiteral:
+		out = "khali"
+	case *ArrayLiteral:
+		out = "[" + f.list(e.Elements) + "]"
+	case *HashLiteral:
+		pairs := make([]string, len(e.Keys))
+		for idx := range e.Keys {
+			pairs[idx] = f.expression(e.Keys[idx], conditionalLevel) + ": " + f.expression(e.Values[idx], conditionalLevel)
+		}
+		out = "{" + strings.Join(pairs, ", ") + "}"
+	case *CallExpression:
+		out = f.expression(e.Function, postfixLevel) + "(" + f.list(e.Arguments) + ")"
+	case *IndexExpression:
+		open := "["
+		if e.Safe {
+			open = "?["
+		}
+		out = f.expression(e.Left, postfixLevel) + open + f.expression(e.Index, conditionalLevel) + "]"
+	case *BinaryExpression:
+		own = precedences[e.Token.Type]
+		// Operators group to the left, so only the right side needs
+		// parentheses at the same level (e.g., a - (b - c)).
+		out = f.expression(e.Left, own) + " " + e.Operator + " " + f.expression(e.Right, own+1)
+	case *PrefixExpression:
+		// ! may be followed by another prefix (e.g., !!x), - only by an
+		// operand, so -(-x) keeps its parentheses.
+		own = prefixLevel
+		operand := prefixLevel
+		if e.Operator == "-" {
+			operand = postfixLevel
+		}
+		out = e.Operator + f.expression(e.Right, operand)
+	case *ConditionalExpression:
+		own = conditionalLevel
+		out = f.expression(e.Condition, LOWEST) + " ? " + f.expression(e.Consequence, conditionalLevel) +
+			" : " + f.expression(e.Alternative, conditionalLevel)
+	default:
+		out = expr.String()
+	}
+	if own < level {
+		return "(" + out + ")"
+	}
+	return out
+}
+
+// quote returns s as a double-quoted string literal, using only the escapes
+// the lexer decodes.
+func quote(s string) string {
+	var sb strings.Builder
+	sb.WriteByte('"')
+	for idx := 0; idx < len(s); idx++ {
+		switch c := s[idx]; c {
+		case '"', '\\':
+			sb.WriteByte('\\')
+			sb.WriteByte(c)
+		case '\n':
+			sb.WriteString(`\n`)
+		case '\t':
+			sb.WriteString(`\t`)
+		case '\r':
+			sb.WriteString(`\r`)
+		default:
+			if c < 0x20 || c == 0x7f {
+				fmt.Fprintf(&sb, `\x%02x`, c)
+			} else {
+				sb.WriteByte(c)
+			}
+		}
+	}
+	sb.WriteByte('"')
+	return sb.String()
+}
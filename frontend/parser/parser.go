@@ -2,7 +2,11 @@ package parser
 
 import (
 	"fmt"
+	"io"
+	"os"
+	"slices"
 	"strconv"
+	"strings"
 
 	"github.com/salillakra/npp/frontend/lexer"
 )
@@ -40,28 +44,55 @@ func (p *Program) String() string {
 	return out
 }
 
+// PrintStatement represents a print statement (e.g., suna x or suna a, b).
 type PrintStatement struct {
-	Tok   lexer.Token
-	Value Expression
+	Tok    lexer.Token
+	Values []Expression
 }
 
-func (ps *PrintStatement) statementNode()     {}
-func (ps *PrintStatement) String() string     { return fmt.Sprintf("suna %s", ps.Value.String()) }
+func (ps *PrintStatement) statementNode() {}
+func (ps *PrintStatement) String() string {
+	values := make([]string, len(ps.Values))
+	for idx, value := range ps.Values {
+		values[idx] = value.String()
+	}
+	return "suna " + strings.Join(values, ", ")
+}
 func (ps *PrintStatement) Token() lexer.Token { return ps.Tok }
 
 // AssignmentStatement represents an assignment statement (e.g., sun x = 69).
+// When it unpacks an array into several variables (e.g., sun k, v = pair),
+// Names holds all of them in order and Name is the first.
 type AssignmentStatement struct {
 	Tok   lexer.Token
 	Name  *Identifier
+	Names []*Identifier
 	Value Expression
 }
 
 func (as *AssignmentStatement) statementNode() {}
 func (as *AssignmentStatement) String() string {
+	if as.Names != nil {
+		names := make([]string, len(as.Names))
+		for idx, name := range as.Names {
+			names[idx] = name.String()
+		}
+		return fmt.Sprintf("sun %s = %s", strings.Join(names, ", "), as.Value.String())
+	}
 	return fmt.Sprintf("sun %s = %s", as.Name.String(), as.Value.String())
 }
 func (as *AssignmentStatement) Token() lexer.Token { return as.Tok }
 
+// ExpressionStatement represents an expression used as a statement (e.g., fhenk("boom")).
+type ExpressionStatement struct {
+	Tok        lexer.Token
+	Expression Expression
+}
+
+func (es *ExpressionStatement) statementNode()     {}
+func (es *ExpressionStatement) String() string     { return es.Expression.String() }
+func (es *ExpressionStatement) Token() lexer.Token { return es.Tok }
+
 // IfStatement represents an if statement (e.g., agar x > 50 { ... }).
 type IfStatement struct {
 	Tok         lexer.Token
@@ -79,6 +110,118 @@ func (is *IfStatement) String() string {
 }
 func (is *IfStatement) Token() lexer.Token { return is.Tok }
 
+// TryStatement represents error handling (e.g., koshish { ... } pakad (e) { ... } aakhir { ... }).
+// Handler and Finally are optional, but at least one of them is present.
+type TryStatement struct {
+	Tok     lexer.Token
+	Body    *BlockStatement
+	Param   *Identifier
+	Handler *BlockStatement
+	Finally *BlockStatement
+}
+
+func (ts *TryStatement) statementNode() {}
+func (ts *TryStatement) String() string {
+	out := "koshish { ... }"
+	if ts.Handler != nil {
+		out += fmt.Sprintf(" pakad (%s) { ... }", ts.Param.String())
+	}
+	if ts.Finally != nil {
+		out += " aakhir { ... }"
+	}
+	return out
+}
+func (ts *TryStatement) Token() lexer.Token { return ts.Tok }
+
+// WhileStatement represents a loop (e.g., grind x < 10 { ... }). Label is
+// set when the loop is prefixed with "name:" so tod and agla can target it.
+// A counted loop (e.g., baar 5 { ... }) has a Count instead of a Condition.
+type WhileStatement struct {
+	Tok       lexer.Token
+	Label     string
+	Condition Expression
+	Count     Expression
+	Body      *BlockStatement
+}
+
+func (ws *WhileStatement) statementNode() {}
+func (ws *WhileStatement) String() string {
+	var out string
+	if ws.Count != nil {
+		out = fmt.Sprintf("baar %s { ... }", ws.Count.String())
+	} else {
+		out = fmt.Sprintf("grind %s { ... }", ws.Condition.String())
+	}
+	if ws.Label != "" {
+		out = ws.Label + ": " + out
+	}
+	return out
+}
+func (ws *WhileStatement) Token() lexer.Token { return ws.Tok }
+
+// BranchStatement represents tod (break) or agla (continue), optionally
+// naming the label of an enclosing loop (e.g., tod bahar).
+type BranchStatement struct {
+	Tok   lexer.Token
+	Label string
+}
+
+func (bs *BranchStatement) statementNode() {}
+func (bs *BranchStatement) String() string {
+	if bs.Label != "" {
+		return bs.Tok.Literal + " " + bs.Label
+	}
+	return bs.Tok.Literal
+}
+func (bs *BranchStatement) Token() lexer.Token { return bs.Tok }
+
+// FunctionStatement represents a function declaration
+// (e.g., glow add(a, b) { fhek a + b }).
+type FunctionStatement struct {
+	Tok        lexer.Token
+	Name       *Identifier
+	Parameters []*Identifier
+	Body       *BlockStatement
+}
+
+func (fs *FunctionStatement) statementNode() {}
+func (fs *FunctionStatement) String() string {
+	params := make([]string, len(fs.Parameters))
+	for idx, param := range fs.Parameters {
+		params[idx] = param.String()
+	}
+	return fmt.Sprintf("glow %s(%s) { ... }", fs.Name.String(), strings.Join(params, ", "))
+}
+func (fs *FunctionStatement) Token() lexer.Token { return fs.Tok }
+
+// ReturnStatement represents fhek, which leaves the enclosing function with
+// the value of its expression (e.g., fhek a + b), or khali if it has none.
+// Several values (fhek a, b) are parsed into one ArrayLiteral, so the caller
+// can unpack them with sun x, y = f().
+type ReturnStatement struct {
+	Tok   lexer.Token
+	Value Expression
+}
+
+func (rs *ReturnStatement) statementNode() {}
+func (rs *ReturnStatement) String() string {
+	if rs.Value != nil {
+		return "fhek " + rs.Value.String()
+	}
+	return "fhek"
+}
+func (rs *ReturnStatement) Token() lexer.Token { return rs.Tok }
+
+// PassStatement represents chhod, a statement that does nothing. It marks a
+// block that is empty on purpose (e.g., agar x { chhod }).
+type PassStatement struct {
+	Tok lexer.Token
+}
+
+func (ps *PassStatement) statementNode()     {}
+func (ps *PassStatement) String() string     { return "chhod" }
+func (ps *PassStatement) Token() lexer.Token { return ps.Tok }
+
 // BlockStatement represents a block of statements (e.g., { suna 42; }).
 type BlockStatement struct {
 	Tok        lexer.Token
@@ -98,7 +241,9 @@ type Identifier struct {
 func (i *Identifier) expressionNode() {}
 func (i *Identifier) String() string  { return i.Value }
 
-// NumberLiteral represents an integer literal (e.g., 69).
+// NumberLiteral represents an integer literal (e.g., 69). A literal made by
+// constant folding keeps the token of the leftmost operand it replaced, so
+// it still reports where the original expression started.
 type NumberLiteral struct {
 	Token lexer.Token
 	Value int64
@@ -107,6 +252,15 @@ type NumberLiteral struct {
 func (nl *NumberLiteral) expressionNode() {}
 func (nl *NumberLiteral) String() string  { return fmt.Sprintf("%d", nl.Value) }
 
+// FloatLiteral represents a float literal (e.g., 3.14).
+type FloatLiteral struct {
+	Token lexer.Token
+	Value float64
+}
+
+func (fl *FloatLiteral) expressionNode() {}
+func (fl *FloatLiteral) String() string  { return strconv.FormatFloat(fl.Value, 'g', -1, 64) }
+
 // StringLiteral represents a string literal (e.g., "You suck!").
 type StringLiteral struct {
 	Token lexer.Token
@@ -116,6 +270,88 @@ type StringLiteral struct {
 func (sl *StringLiteral) expressionNode() {}
 func (sl *StringLiteral) String() string  { return fmt.Sprintf("%q", sl.Value) }
 
+// BooleanLiteral represents a boolean literal (e.g., yas, nah).
+type BooleanLiteral struct {
+	Token lexer.Token
+	Value bool
+}
+
+func (bl *BooleanLiteral) expressionNode() {}
+func (bl *BooleanLiteral) String() string  { return bl.Token.Literal }
+
+// NullLiteral represents khali, the absence of a value.
+type NullLiteral struct {
+	Token lexer.Token
+}
+
+func (nl *NullLiteral) expressionNode() {}
+func (nl *NullLiteral) String() string  { return "khali" }
+
+// ArrayLiteral represents an array literal (e.g., [1, "two", yas]).
+type ArrayLiteral struct {
+	Token    lexer.Token
+	Elements []Expression
+}
+
+func (al *ArrayLiteral) expressionNode() {}
+func (al *ArrayLiteral) String() string {
+	elements := make([]string, len(al.Elements))
+	for idx, el := range al.Elements {
+		elements[idx] = el.String()
+	}
+	return "[" + strings.Join(elements, ", ") + "]"
+}
+
+// HashLiteral represents a hash literal (e.g., {"a": 1, "b": 2}).
+// Keys and Values are kept in source order.
+type HashLiteral struct {
+	Token  lexer.Token
+	Keys   []Expression
+	Values []Expression
+}
+
+func (hl *HashLiteral) expressionNode() {}
+func (hl *HashLiteral) String() string {
+	pairs := make([]string, len(hl.Keys))
+	for idx := range hl.Keys {
+		pairs[idx] = fmt.Sprintf("%s: %s", hl.Keys[idx].String(), hl.Values[idx].String())
+	}
+	return "{" + strings.Join(pairs, ", ") + "}"
+}
+
+// CallExpression represents a call (e.g., has_key(h, "a")).
+type CallExpression struct {
+	Token     lexer.Token
+	Function  Expression
+	Arguments []Expression
+}
+
+func (ce *CallExpression) expressionNode() {}
+func (ce *CallExpression) String() string {
+	args := make([]string, len(ce.Arguments))
+	for idx, arg := range ce.Arguments {
+		args[idx] = arg.String()
+	}
+	return fmt.Sprintf("%s(%s)", ce.Function.String(), strings.Join(args, ", "))
+}
+
+// IndexExpression represents an index access (e.g., h["a"]). A safe index
+// (e.g., a?[5]) gives khali instead of an error when there is nothing there.
+type IndexExpression struct {
+	Token lexer.Token
+	Left  Expression
+	Index Expression
+	Safe  bool
+}
+
+func (ie *IndexExpression) expressionNode() {}
+func (ie *IndexExpression) String() string {
+	if ie.Safe {
+		return fmt.Sprintf("(%s?[%s])", ie.Left.String(), ie.Index.String())
+	}
+	return fmt.Sprintf("(%s[%s])", ie.Left.String(), ie.Index.String())
+}
+
 // BinaryExpression represents a binary operation (e.g., x + 10, x > y).
 type BinaryExpression struct {
 	Token    lexer.Token
@@ -129,12 +365,70 @@ func (be *BinaryExpression) String() string {
 	return fmt.Sprintf("(%s %s %s)", be.Left.String(), be.Operator, be.Right.String())
 }
 
+// PrefixExpression represents a unary operation (e.g., -len(s) or !done).
+type PrefixExpression struct {
+	Token    lexer.Token
+	Operator string
+	Right    Expression
+}
+
+func (pe *PrefixExpression) expressionNode() {}
+func (pe *PrefixExpression) String() string {
+	return fmt.Sprintf("(%s%s)", pe.Operator, pe.Right.String())
+}
+
+// ConditionalExpression represents a ternary (e.g., x > 0 ? "pos" : "neg").
+type ConditionalExpression struct {
+	Token       lexer.Token
+	Condition   Expression
+	Consequence Expression
+	Alternative Expression
+}
+
+func (ce *ConditionalExpression) expressionNode() {}
+func (ce *ConditionalExpression) String() string {
+	return fmt.Sprintf("(%s ? %s : %s)", ce.Condition.String(), ce.Consequence.String(), ce.Alternative.String())
+}
+
+// ParseError describes a syntax error at a source position.
+type ParseError struct {
+	Line    int
+	Column  int
+	Message string
+}
+
+func (e ParseError) Error() string {
+	return fmt.Sprintf("Error at line %d, col %d: %s", e.Line, e.Column, e.Message)
+}
+
 // Parser holds the lexer and current/peek tokens.
 type Parser struct {
 	l         *lexer.Lexer
 	curToken  lexer.Token
 	peekToken lexer.Token
+	errors    []ParseError
+	loops     []string // labels of the enclosing loops, innermost last ("" if unlabeled)
+	functions int      // number of enclosing glow bodies
+	dropped   bool     // the last nil statement was read to its end and already reported
 	Debug     bool
+
+	// StrictSemicolons makes a missing ; between two statements on the same
+	// line a syntax error. Statements on separate lines, or after a closing
+	// brace, never need one.
+	StrictSemicolons bool
+
+	// Quiet stops syntax errors from being printed as they are found; they
+	// are still available from Errors.
+	Quiet bool
+
+	// KeepConstants turns off constant folding, so 2 + 3 stays as written
+	// instead of becoming 5. Format uses it to print literals unchanged.
+	KeepConstants bool
+
+	// Out receives syntax errors as they are found, and Debug output. nil
+	// means os.Stdout. Point it at the same writer as Interpreter.Out to keep
+	// them together with the program's output.
+	Out io.Writer
 }
 
 // New creates a new Parser.
@@ -145,6 +439,33 @@ func New(l *lexer.Lexer, Debug bool) *Parser {
 	return p
 }
 
+// Errors returns the syntax errors reported so far, in source order.
+func (p *Parser) Errors() []ParseError {
+	return p.errors
+}
+
+// errorf records a syntax error at the current token and prints it unless Quiet is set.
+func (p *Parser) errorf(format string, args ...interface{}) {
+	p.errorAt(p.curToken, format, args...)
+}
+
+// errorAt is errorf for an error at tok rather than the current token.
+func (p *Parser) errorAt(tok lexer.Token, format string, args ...interface{}) {
+	err := ParseError{Line: tok.Line, Column: tok.Column, Message: fmt.Sprintf(format, args...)}
+	p.errors = append(p.errors, err)
+	if !p.Quiet {
+		fmt.Fprintln(p.writer(), err.Error())
+	}
+}
+
+// writer returns where syntax errors and Debug output go.
+func (p *Parser) writer() io.Writer {
+	if p.Out != nil {
+		return p.Out
+	}
+	return os.Stdout
+}
+
 // nextToken advances to the next token.
 func (p *Parser) nextToken() {
 	p.curToken = p.peekToken
@@ -156,14 +477,17 @@ func (p *Parser) ParseProgram() *Program {
 	program := &Program{Statements: []Statement{}}
 	for p.curToken.Type != lexer.EOF {
 		if p.Debug {
-			fmt.Printf("Debug: Parsing statement at %v (line %d, col %d)\n", p.curToken, p.curToken.Line, p.curToken.Column)
+			fmt.Fprintf(p.writer(), "Debug: Parsing statement at %v (line %d, col %d)\n", p.curToken, p.curToken.Line, p.curToken.Column)
 
 		}
 		stmt := p.parseStatement()
 		if stmt != nil {
 			program.Statements = append(program.Statements, stmt)
+			p.checkSeparator(stmt)
+		} else if p.dropped {
+			p.dropped = false
 		} else {
-			fmt.Printf("Error at line %d, col %d: Invalid statement, got %s // Keep it together, genius!\n", p.curToken.Line, p.curToken.Column, p.curToken.Type)
+			p.errorf("Invalid statement, got %s // Keep it together, genius!", p.curToken.Type)
 			p.nextToken()
 		}
 		// Skip optional semicolons
@@ -174,6 +498,23 @@ func (p *Parser) ParseProgram() *Program {
 	return program
 }
 
+// checkSeparator reports a missing ; after stmt in StrictSemicolons mode,
+// when another statement follows on the same line (e.g., sun a = 1 sun b = 2).
+func (p *Parser) checkSeparator(stmt Statement) {
+	if !p.StrictSemicolons || p.curToken.AfterNewline {
+		return
+	}
+	switch p.curToken.Type {
+	case lexer.SEMICOLON, lexer.RBRACE, lexer.EOF:
+		return
+	}
+	switch stmt.(type) {
+	case *IfStatement, *WhileStatement, *TryStatement, *BlockStatement, *FunctionStatement:
+		return // These end with a closing brace.
+	}
+	p.errorf("Missing ; before %s on the same line // Semicolons aren't optional here, genius!", p.curToken.Literal)
+}
+
 // parseStatement parses a single statement.
 func (p *Parser) parseStatement() Statement {
 	switch p.curToken.Type {
@@ -182,60 +523,198 @@ func (p *Parser) parseStatement() Statement {
 		stmt := &AssignmentStatement{Tok: p.curToken}
 		p.nextToken()
 		if p.curToken.Type != lexer.IDENT {
-			fmt.Printf("Error at line %d, col %d: Expected identifier after SUN, got %s // My grandma codes better!\n", p.curToken.Line, p.curToken.Column, p.curToken.Type)
+			p.errorf("Expected identifier after SUN, got %s // My grandma codes better!", p.curToken.Type)
 			return nil
 		}
 		stmt.Name = &Identifier{Token: p.curToken, Value: p.curToken.Literal}
 		p.nextToken()
+		if p.curToken.Type == lexer.COMMA && !p.parseAssignmentNames(stmt) {
+			return nil
+		}
 		if p.curToken.Type != lexer.ASSIGN {
-			fmt.Printf("Error at line %d, col %d: Expected = after identifier, got %s // Yo, nice one, jerk!\n", p.curToken.Line, p.curToken.Column, p.curToken.Type)
+			p.errorf("Expected = after identifier, got %s // Yo, nice one, jerk!", p.curToken.Type)
 			return nil
 		}
 		p.nextToken()
 		stmt.Value = p.parseExpression(LOWEST)
 		if stmt.Value == nil {
-			fmt.Printf("Error at line %d, col %d: Expected expression after =, got %s // You absolute walnut!\n", p.curToken.Line, p.curToken.Column, p.curToken.Type)
+			p.errorf("Expected expression after =, got %s // You absolute walnut!", p.curToken.Type)
 			return nil
 		}
 		return stmt
+	// The parse functions below return a typed nil on error. Turn that into
+	// a plain nil so no half-built statement reaches the AST.
 	case lexer.SUNA:
-		return p.parsePrintStatement()
+		if stmt := p.parsePrintStatement(); stmt != nil {
+			return stmt
+		}
+		return nil
 	case lexer.AGAR:
-		return p.parseIfStatement()
+		if stmt := p.parseIfStatement(); stmt != nil {
+			return stmt
+		}
+		return nil
+	case lexer.KOSHISH:
+		if stmt := p.parseTryStatement(); stmt != nil {
+			return stmt
+		}
+		return nil
+	case lexer.GRIND, lexer.BAAR:
+		if stmt := p.parseWhileStatement(""); stmt != nil {
+			return stmt
+		}
+		return nil
+	case lexer.TOD, lexer.AGLA:
+		if stmt := p.parseBranchStatement(); stmt != nil {
+			return stmt
+		}
+		return nil
+	case lexer.GLOW:
+		if stmt := p.parseFunctionStatement(); stmt != nil {
+			return stmt
+		}
+		return nil
+	case lexer.FHEK:
+		if stmt := p.parseReturnStatement(); stmt != nil {
+			return stmt
+		}
+		return nil
+	case lexer.CHHOD:
+		stmt := &PassStatement{Tok: p.curToken}
+		p.nextToken()
+		return stmt
+	case lexer.LBRACE:
+		// A bare block opens a new scope.
+		block := p.parseBlockStatement()
+		p.nextToken() // Skip closing brace
+		if block == nil {
+			return nil
+		}
+		return block
+	case lexer.IDENT, lexer.INT, lexer.FLOAT, lexer.STRING, lexer.YAS, lexer.NAH, lexer.LBRACKET, lexer.MINUS:
+		if p.curToken.Type == lexer.IDENT && p.peekToken.Type == lexer.COLON {
+			return p.parseLabeledStatement()
+		}
+		if p.curToken.Type == lexer.IDENT && startsOperand(p.peekToken.Type) {
+			// Two operands in a row: most likely a misspelled keyword like "agr x > 1".
+			if suggestion := suggestKeyword(p.curToken.Literal); suggestion != "" {
+				p.errorf("Unknown identifier '%s', did you mean '%s'? // Learn to spell, genius!", p.curToken.Literal, suggestion)
+			} else {
+				p.errorf("Unknown identifier '%s' at start of statement // Keep it together, genius!", p.curToken.Literal)
+			}
+			return nil
+		}
+		stmt := &ExpressionStatement{Tok: p.curToken}
+		stmt.Expression = p.parseExpression(LOWEST)
+		if stmt.Expression == nil {
+			return nil
+		}
+		return stmt
 	default:
-		fmt.Printf("Error at line %d, col %d: Invalid statement, got %s // Keep it together, genius!\n", p.curToken.Line, p.curToken.Column, p.curToken.Type)
+		p.errorf("Invalid statement, got %s // Keep it together, genius!", p.curToken.Type)
 		return nil
 	}
 }
 
-// parsePrintStatement parses a print statement (e.g., suna "You suck!" or suna x).
+// parseAssignmentNames parses the rest of the names in sun a, b, c = ...
+// into stmt, starting at the comma after the first, and reports whether it
+// succeeded.
+func (p *Parser) parseAssignmentNames(stmt *AssignmentStatement) bool {
+	stmt.Names = []*Identifier{stmt.Name}
+	for p.curToken.Type == lexer.COMMA {
+		p.nextToken()
+		if p.curToken.Type != lexer.IDENT {
+			p.errorf("Expected identifier after , in sun, got %s // My grandma codes better!", p.curToken.Type)
+			return false
+		}
+		for _, name := range stmt.Names {
+			if name.Value == p.curToken.Literal {
+				p.errorf("Variable '%s' appears twice in one sun // Pick a lane, genius!", p.curToken.Literal)
+				return false
+			}
+		}
+		stmt.Names = append(stmt.Names, &Identifier{Token: p.curToken, Value: p.curToken.Literal})
+		p.nextToken()
+	}
+	return true
+}
+
+// parsePrintStatement parses a print statement with one or more
+// comma-separated values (e.g., suna "You suck!" or suna x, y). The values may
+// also be wrapped in parentheses like a call (e.g., suna(x, y)).
 func (p *Parser) parsePrintStatement() *PrintStatement {
 	stmt := &PrintStatement{Tok: p.curToken}
 	p.nextToken()
-	stmt.Value = p.parseExpression(LOWEST)
-	if stmt.Value == nil {
-		fmt.Printf("Error at line %d, col %d: Expected expression after suna, got %s // You absolute walnut!\n", p.curToken.Line, p.curToken.Column, p.curToken.Type)
-		return nil
+	if p.curToken.Type == lexer.LPAREN {
+		if !p.parsePrintGroup(stmt) {
+			return nil
+		}
+		if p.curToken.Type != lexer.COMMA {
+			return stmt
+		}
+		p.nextToken()
 	}
-	return stmt
+	for {
+		value := p.parseExpression(LOWEST)
+		if value == nil {
+			p.errorf("Expected expression after suna, got %s // You absolute walnut!", p.curToken.Type)
+			return nil
+		}
+		stmt.Values = append(stmt.Values, value)
+		if p.curToken.Type != lexer.COMMA {
+			return stmt
+		}
+		p.nextToken()
+	}
+}
+
+// parsePrintGroup parses the parenthesized form suna(a, b) into stmt and
+// reports whether it succeeded. A single value followed by more of an
+// expression, as in suna (x)[0] + 1, is instead read as that expression.
+func (p *Parser) parsePrintGroup(stmt *PrintStatement) bool {
+	stmt.Values = p.parseExpressionList(lexer.RPAREN)
+	if stmt.Values == nil {
+		return false
+	}
+	if len(stmt.Values) == 0 {
+		p.errorf("Expected expression after suna, got %s // You absolute walnut!", lexer.RPAREN)
+		return false
+	}
+	continues := isOperator(p.curToken.Type) || p.curToken.Type == lexer.LBRACKET ||
+		p.curToken.Type == lexer.LPAREN || p.curToken.Type == lexer.COMMA ||
+		p.curToken.Type == lexer.QUESTION
+	if !continues {
+		return true
+	}
+	if len(stmt.Values) > 1 {
+		p.errorf("Unexpected %s after suna(...) // Pick one: print or compute, genius!", p.curToken.Literal)
+		return false
+	}
+	value := p.parsePostfix(stmt.Values[0])
+	if value == nil {
+		return false
+	}
+	stmt.Values[0] = p.parseInfix(value, LOWEST)
+	return stmt.Values[0] != nil
 }
 
-// parseIfStatement parses an if statement (e.g., agar x > 50 { ... } magar { ... }).
+// parseIfStatement parses an if statement (e.g., agar x > 50 { ... } magar { ... }),
+// including else-if chains (e.g., agar a { ... } magar agar b { ... } magar { ... }).
 func (p *Parser) parseIfStatement() *IfStatement {
 	stmt := &IfStatement{Tok: p.curToken}
 	p.nextToken()
 	stmt.Condition = p.parseExpression(LOWEST)
 	if stmt.Condition == nil {
-		fmt.Printf("Error at line %d, col %d: Expected condition after agar, got %s // This syntax sucks, fix it!\n", p.curToken.Line, p.curToken.Column, p.curToken.Type)
+		p.errorf("Expected condition after agar, got %s // This syntax sucks, fix it!", p.curToken.Type)
 		return nil
 	}
 	if p.curToken.Type != lexer.LBRACE {
-		fmt.Printf("Error at line %d, col %d: Expected { after condition, got %s // Get your braces together, loser!\n", p.curToken.Line, p.curToken.Column, p.curToken.Type)
+		p.errorf("Expected { after condition, got %s // Get your braces together, loser!", p.curToken.Type)
 		return nil
 	}
 	stmt.Consequence = p.parseBlockStatement()
 	if stmt.Consequence == nil {
-		fmt.Printf("Error at line %d, col %d: Invalid block after agar // This ain't working, jerk!\n", p.curToken.Line, p.curToken.Column)
+		p.errorf("Invalid block after agar // This ain't working, jerk!")
 		return nil
 	}
 	p.nextToken() // Skip closing brace
@@ -244,13 +723,23 @@ func (p *Parser) parseIfStatement() *IfStatement {
 	}
 	if p.curToken.Type == lexer.MAGAR {
 		p.nextToken()
+		if p.curToken.Type == lexer.AGAR {
+			// "magar agar" chains another if as the whole else block.
+			tok := p.curToken
+			elseIf := p.parseIfStatement()
+			if elseIf == nil {
+				return nil
+			}
+			stmt.Alternative = &BlockStatement{Tok: tok, Statements: []Statement{elseIf}}
+			return stmt
+		}
 		if p.curToken.Type != lexer.LBRACE {
-			fmt.Printf("Error at line %d, col %d: Expected { after magar, got %s // Get your braces together, loser!\n", p.curToken.Line, p.curToken.Column, p.curToken.Type)
+			p.errorf("Expected { after magar, got %s // Get your braces together, loser!", p.curToken.Type)
 			return nil
 		}
 		stmt.Alternative = p.parseBlockStatement()
 		if stmt.Alternative == nil {
-			fmt.Printf("Error at line %d, col %d: Invalid block after magar // This ain't working, jerk!\n", p.curToken.Line, p.curToken.Column)
+			p.errorf("Invalid block after magar // This ain't working, jerk!")
 			return nil
 		}
 		p.nextToken() // Skip closing brace
@@ -258,6 +747,257 @@ func (p *Parser) parseIfStatement() *IfStatement {
 	return stmt
 }
 
+// parseTryStatement parses error handling (e.g., koshish { ... } pakad (e) { ... } aakhir { ... }).
+func (p *Parser) parseTryStatement() *TryStatement {
+	stmt := &TryStatement{Tok: p.curToken}
+	p.nextToken()
+	if p.curToken.Type != lexer.LBRACE {
+		p.errorf("Expected { after koshish, got %s // Get your braces together, loser!", p.curToken.Type)
+		return nil
+	}
+	stmt.Body = p.parseBlockStatement()
+	if stmt.Body == nil {
+		p.errorf("Invalid block after koshish // This ain't working, jerk!")
+		return nil
+	}
+	p.nextToken() // Skip closing brace
+	if p.curToken.Type == lexer.PAKAD {
+		p.nextToken()
+		if p.curToken.Type != lexer.LPAREN || p.peekToken.Type != lexer.IDENT {
+			p.errorf("Expected (name) after pakad, got %s // Name your error, you walnut!", p.curToken.Type)
+			return nil
+		}
+		p.nextToken()
+		stmt.Param = &Identifier{Token: p.curToken, Value: p.curToken.Literal}
+		p.nextToken()
+		if p.curToken.Type != lexer.RPAREN {
+			p.errorf("Expected ) after pakad name, got %s // Close your parens, loser!", p.curToken.Type)
+			return nil
+		}
+		p.nextToken()
+		if p.curToken.Type != lexer.LBRACE {
+			p.errorf("Expected { after pakad, got %s // Get your braces together, loser!", p.curToken.Type)
+			return nil
+		}
+		stmt.Handler = p.parseBlockStatement()
+		if stmt.Handler == nil {
+			p.errorf("Invalid block after pakad // This ain't working, jerk!")
+			return nil
+		}
+		p.nextToken() // Skip closing brace
+	}
+	if p.curToken.Type == lexer.AAKHIR {
+		p.nextToken()
+		if p.curToken.Type != lexer.LBRACE {
+			p.errorf("Expected { after aakhir, got %s // Get your braces together, loser!", p.curToken.Type)
+			return nil
+		}
+		stmt.Finally = p.parseBlockStatement()
+		if stmt.Finally == nil {
+			p.errorf("Invalid block after aakhir // This ain't working, jerk!")
+			return nil
+		}
+		p.nextToken() // Skip closing brace
+	}
+	if stmt.Handler == nil && stmt.Finally == nil {
+		p.errorf("Expected pakad or aakhir after koshish block, got %s // Try without catch? Bold move, genius!", p.curToken.Type)
+		return nil
+	}
+	return stmt
+}
+
+// parseWhileStatement parses a loop (e.g., grind x < 10 { ... } or
+// baar 5 { ... }) with the given label, which is "" for an unlabeled loop.
+func (p *Parser) parseWhileStatement(label string) *WhileStatement {
+	stmt := &WhileStatement{Tok: p.curToken, Label: label}
+	p.nextToken()
+	if stmt.Tok.Type == lexer.BAAR {
+		stmt.Count = p.parseExpression(LOWEST)
+		if stmt.Count == nil {
+			p.errorf("Expected count after baar, got %s // How many times, genius?", p.curToken.Type)
+			return nil
+		}
+		if p.curToken.Type != lexer.LBRACE {
+			p.errorf("Expected { after count, got %s // Get your braces together, loser!", p.curToken.Type)
+			return nil
+		}
+	} else {
+		stmt.Condition = p.parseExpression(LOWEST)
+		if stmt.Condition == nil {
+			p.errorf("Expected condition after grind, got %s // This syntax sucks, fix it!", p.curToken.Type)
+			return nil
+		}
+		if p.curToken.Type != lexer.LBRACE {
+			p.errorf("Expected { after condition, got %s // Get your braces together, loser!", p.curToken.Type)
+			return nil
+		}
+	}
+	p.loops = append(p.loops, label)
+	errs := len(p.errors)
+	stmt.Body = p.parseBlockStatement()
+	p.loops = p.loops[:len(p.loops)-1]
+	p.nextToken() // Skip closing brace
+	if len(p.errors) > errs {
+		// A body that lost a statement may have lost its only tod, so
+		// running what is left could spin forever. Drop the whole loop.
+		p.dropped = true
+		return nil
+	}
+	return stmt
+}
+
+// parseLabeledStatement parses a labeled loop (e.g., bahar: grind yas { ... }
+// or bahar: baar 3 { ... }).
+func (p *Parser) parseLabeledStatement() Statement {
+	label := p.curToken.Literal
+	comment := p.curToken.LeadingComment
+	if slices.Contains(p.loops, label) {
+		p.errorf("Label '%s' is already used by an enclosing loop // Be more creative, genius!", label)
+		return nil
+	}
+	p.nextToken() // Skip the label
+	p.nextToken() // Skip ':'
+	if p.curToken.Type != lexer.GRIND && p.curToken.Type != lexer.BAAR {
+		p.errorf("Expected a loop after label '%s:', got %s // Labels are for loops, genius!", label, p.curToken.Type)
+		return nil
+	}
+	if stmt := p.parseWhileStatement(label); stmt != nil {
+		// The comment above the label documents the loop.
+		if comment != "" {
+			stmt.Tok.LeadingComment = comment
+		}
+		return stmt
+	}
+	return nil
+}
+
+// parseBranchStatement parses tod or agla with an optional loop label.
+func (p *Parser) parseBranchStatement() *BranchStatement {
+	stmt := &BranchStatement{Tok: p.curToken}
+	p.nextToken()
+	// A name on the next line starts the next statement (e.g., agla then
+	// f() on its own line), so only a name on the same line is a label.
+	label := p.curToken
+	if label.Type == lexer.IDENT && !label.AfterNewline {
+		stmt.Label = label.Literal
+		p.nextToken()
+	}
+	// Both errors leave the whole statement read, so mark it dropped and
+	// let the caller carry on from the next token.
+	if len(p.loops) == 0 {
+		p.errorAt(stmt.Tok, "%s used outside of a loop // Nothing to escape from, genius!", stmt.Tok.Literal)
+		p.dropped = true
+		return nil
+	}
+	if stmt.Label != "" && !slices.Contains(p.loops, stmt.Label) {
+		p.errorAt(label, "Unknown loop label '%s' // Which loop, genius?", stmt.Label)
+		p.dropped = true
+		return nil
+	}
+	return stmt
+}
+
+// parseFunctionStatement parses a function declaration
+// (e.g., glow add(a, b) { fhek a + b }).
+func (p *Parser) parseFunctionStatement() *FunctionStatement {
+	stmt := &FunctionStatement{Tok: p.curToken}
+	p.nextToken()
+	if p.curToken.Type != lexer.IDENT {
+		p.errorf("Expected function name after glow, got %s // Name your stuff, genius!", p.curToken.Type)
+		return nil
+	}
+	stmt.Name = &Identifier{Token: p.curToken, Value: p.curToken.Literal}
+	p.nextToken()
+	if p.curToken.Type != lexer.LPAREN {
+		p.errorf("Expected ( after function name, got %s // Where are the parameters, loser?", p.curToken.Type)
+		return nil
+	}
+	p.nextToken()
+	stmt.Parameters = []*Identifier{}
+	for p.curToken.Type != lexer.RPAREN {
+		if p.curToken.Type != lexer.IDENT {
+			p.errorf("Expected parameter name, got %s // My grandma codes better!", p.curToken.Type)
+			return nil
+		}
+		for _, param := range stmt.Parameters {
+			if param.Value == p.curToken.Literal {
+				p.errorf("Parameter '%s' appears twice in glow %s // Pick a lane, genius!", p.curToken.Literal, stmt.Name.Value)
+				return nil
+			}
+		}
+		stmt.Parameters = append(stmt.Parameters, &Identifier{Token: p.curToken, Value: p.curToken.Literal})
+		p.nextToken()
+		if p.curToken.Type == lexer.COMMA {
+			p.nextToken()
+		} else if p.curToken.Type != lexer.RPAREN {
+			p.errorf("Expected , or ) in parameters, got %s // Close your lists, loser!", p.curToken.Type)
+			return nil
+		}
+	}
+	p.nextToken() // Skip ')'
+	if p.curToken.Type != lexer.LBRACE {
+		p.errorf("Expected { after parameters, got %s // Get your braces together, loser!", p.curToken.Type)
+		return nil
+	}
+	// tod and agla can't reach loops outside the function.
+	loops := p.loops
+	p.loops = nil
+	p.functions++
+	stmt.Body = p.parseBlockStatement()
+	p.functions--
+	p.loops = loops
+	p.nextToken() // Skip closing brace
+	return stmt
+}
+
+// parseReturnStatement parses fhek with an optional value (e.g., fhek a + b)
+// or a comma-separated list of values (e.g., fhek a, b) on the same line.
+func (p *Parser) parseReturnStatement() *ReturnStatement {
+	stmt := &ReturnStatement{Tok: p.curToken}
+	p.nextToken()
+	switch p.curToken.Type {
+	case lexer.SEMICOLON, lexer.RBRACE, lexer.EOF:
+	default:
+		// Like a tod label, the value must start on the same line.
+		if p.curToken.AfterNewline {
+			break
+		}
+		if stmt.Value = p.parseReturnValue(); stmt.Value == nil {
+			return nil
+		}
+	}
+	if p.functions == 0 {
+		// The value has been read too, so the caller carries on after it.
+		p.errorAt(stmt.Tok, "fhek used outside of a function // Nowhere to throw it back to, genius!")
+		p.dropped = true
+		return nil
+	}
+	return stmt
+}
+
+// parseReturnValue parses the value after fhek. Several comma-separated
+// values are gathered into one ArrayLiteral.
+func (p *Parser) parseReturnValue() Expression {
+	value := p.parseExpression(LOWEST)
+	if value == nil {
+		p.errorf("Expected expression after fhek, got %s // Return what, genius?", p.curToken.Type)
+		return nil
+	}
+	if p.curToken.Type != lexer.COMMA {
+		return value
+	}
+	values := &ArrayLiteral{Token: p.curToken, Elements: []Expression{value}}
+	for p.curToken.Type == lexer.COMMA {
+		p.nextToken()
+		value := p.parseExpression(LOWEST)
+		if value == nil {
+			return nil
+		}
+		values.Elements = append(values.Elements, value)
+	}
+	return values
+}
+
 // parseBlockStatement parses a block of statements (e.g., { suna 42; }).
 func (p *Parser) parseBlockStatement() *BlockStatement {
 	block := &BlockStatement{Tok: p.curToken, Statements: []Statement{}}
@@ -266,6 +1006,9 @@ func (p *Parser) parseBlockStatement() *BlockStatement {
 		stmt := p.parseStatement()
 		if stmt != nil {
 			block.Statements = append(block.Statements, stmt)
+			p.checkSeparator(stmt)
+		} else if p.dropped {
+			p.dropped = false
 		} else {
 			p.nextToken()
 		}
@@ -274,8 +1017,9 @@ func (p *Parser) parseBlockStatement() *BlockStatement {
 		}
 	}
 	if p.curToken.Type != lexer.RBRACE {
-		fmt.Printf("Error at line %d, col %d: Expected } to close block, got %s // Close your blocks, you walnut!\n", p.curToken.Line, p.curToken.Column, p.curToken.Type)
-		return nil
+		// Only the end of input gets here. Keep what was parsed so one
+		// missing brace doesn't also throw away every statement after it.
+		p.errorf("Unclosed { opened at line %d, col %d // Close your blocks, you walnut!", block.Tok.Line, block.Tok.Column)
 	}
 	return block
 }
@@ -283,13 +1027,17 @@ func (p *Parser) parseBlockStatement() *BlockStatement {
 // Precedence levels for operators
 const (
 	LOWEST      = 1
-	EQUALS      = 2 // ==, !=
-	LESSGREATER = 3 // <, >, <=, >=
-	SUM         = 4 // +, -
-	PRODUCT     = 5 // *, /
+	LOGICAL_OR  = 2 // ||
+	LOGICAL_AND = 3 // &&
+	EQUALS      = 4 // ==, !=
+	LESSGREATER = 5 // <, >, <=, >=
+	SUM         = 6 // +, -
+	PRODUCT     = 7 // *, /, %
 )
 
 var precedences = map[lexer.TokenType]int{
+	lexer.OR:       LOGICAL_OR,
+	lexer.AND:      LOGICAL_AND,
 	lexer.EQ:       EQUALS,
 	lexer.NOT_EQ:   EQUALS,
 	lexer.LT:       LESSGREATER,
@@ -300,32 +1048,62 @@ var precedences = map[lexer.TokenType]int{
 	lexer.MINUS:    SUM,
 	lexer.ASTERISK: PRODUCT,
 	lexer.SLASH:    PRODUCT,
+	lexer.PERCENT:  PRODUCT,
 }
 
 // parseExpression parses an expression with precedence handling.
 func (p *Parser) parseExpression(precedence int) Expression {
-	var left Expression
-	if p.curToken.Type == lexer.MINUS {
-		token := p.curToken
+	left := p.parseUnary()
+	if left == nil {
+		return nil
+	}
+	return p.parseInfix(left, precedence)
+}
+
+// parseUnary parses an operand with any leading - or ! (e.g., -5, -len(s),
+// !done or !!x). Both bind tighter than every infix operator, so !a == b
+// compares !a with b.
+func (p *Parser) parseUnary() Expression {
+	token := p.curToken
+	switch token.Type {
+	case lexer.MINUS:
 		p.nextToken()
-		if p.curToken.Type != lexer.INT {
-			fmt.Printf("Error at line %d, col %d: Expected number after -, got %s // Numbers too hard for you, huh?\n", p.curToken.Line, p.curToken.Column, p.curToken.Type)
-			return nil
+		switch p.curToken.Type {
+		case lexer.INT:
+			value, ok := p.parseInt()
+			if !ok {
+				return nil
+			}
+			p.nextToken()
+			return &NumberLiteral{Token: token, Value: -value}
+		case lexer.FLOAT:
+			value, ok := p.parseFloat()
+			if !ok {
+				return nil
+			}
+			p.nextToken()
+			return &FloatLiteral{Token: token, Value: -value}
 		}
-		value, err := strconv.ParseInt(p.curToken.Literal, 10, 64)
-		if err != nil {
-			fmt.Printf("Error at line %d, col %d: Invalid number %s // Numbers too hard for you, huh?\n", p.curToken.Line, p.curToken.Column, p.curToken.Literal)
+		// Anything else is negated at runtime (e.g., -len(s) or -x[0]).
+		right := p.parsePrimary()
+		if right == nil {
 			return nil
 		}
-		left = &NumberLiteral{Token: token, Value: -value}
+		return &PrefixExpression{Token: token, Operator: "-", Right: right}
+	case lexer.BANG:
 		p.nextToken()
-	} else {
-		left = p.parsePrimary()
-		if left == nil {
+		right := p.parseUnary()
+		if right == nil {
 			return nil
 		}
+		return &PrefixExpression{Token: token, Operator: "!", Right: right}
 	}
+	return p.parsePrimary()
+}
 
+// parseInfix extends left with binary operators that bind tighter than
+// precedence, and with a ternary when parsing a whole expression.
+func (p *Parser) parseInfix(left Expression, precedence int) Expression {
 	for p.curToken.Type != lexer.EOF &&
 		p.curToken.Type != lexer.SEMICOLON &&
 		p.curToken.Type != lexer.RBRACE &&
@@ -338,26 +1116,122 @@ func (p *Parser) parseExpression(precedence int) Expression {
 		p.nextToken()
 		right := p.parseExpression(p.getPrecedence(op.Type))
 		if right == nil {
-			fmt.Printf("Error at line %d, col %d: Expected expression after %s // What's this nonsense, loser?\n", p.curToken.Line, p.curToken.Column, op.Literal)
+			p.errorf("Expected expression after %s // What's this nonsense, loser?", op.Literal)
 			return nil
 		}
 		left = &BinaryExpression{Token: op, Left: left, Operator: op.Literal, Right: right}
+		if !p.KeepConstants {
+			left = foldConstant(left.(*BinaryExpression))
+		}
+	}
+	if precedence == LOWEST && p.curToken.Type == lexer.QUESTION {
+		return p.parseConditional(left)
 	}
 	return left
 }
 
-// parsePrimary parses a primary expression (number, string, or identifier).
+// foldConstant replaces integer arithmetic on two literals with its result
+// (e.g., 2 + 3 becomes 5) so hot loops don't redo it on every pass. Folding
+// only happens when the operands and the result all fit in 32 bits, where
+// the interpreter gives the same answer whatever its IntWidth. Division and
+// modulo by zero are left alone so they fail at runtime like any other.
+func foldConstant(be *BinaryExpression) Expression {
+	left, ok1 := be.Left.(*NumberLiteral)
+	right, ok2 := be.Right.(*NumberLiteral)
+	if !ok1 || !ok2 || !fitsInt32(left.Value) || !fitsInt32(right.Value) {
+		return be
+	}
+	var value int64
+	switch be.Operator {
+	case "+":
+		value = left.Value + right.Value
+	case "-":
+		value = left.Value - right.Value
+	case "*":
+		value = left.Value * right.Value
+	case "/", "%":
+		if right.Value == 0 {
+			return be
+		}
+		if be.Operator == "/" {
+			value = left.Value / right.Value
+		} else {
+			value = left.Value % right.Value
+		}
+	default:
+		return be
+	}
+	if !fitsInt32(value) {
+		return be
+	}
+	return &NumberLiteral{Token: left.Token, Value: value}
+}
+
+func fitsInt32(n int64) bool {
+	return n == int64(int32(n))
+}
+
+// parseConditional parses the branches of a ternary whose condition has
+// already been parsed. Ternaries bind loosest and nest to the right, so
+// a ? b : c ? d : e reads as a ? b : (c ? d : e).
+func (p *Parser) parseConditional(condition Expression) Expression {
+	expr := &ConditionalExpression{Token: p.curToken, Condition: condition}
+	p.nextToken()
+	expr.Consequence = p.parseExpression(LOWEST)
+	if expr.Consequence == nil {
+		return nil
+	}
+	if p.curToken.Type != lexer.COLON {
+		p.errorf("Expected : in ternary, got %s // Finish your thought, genius!", p.curToken.Type)
+		return nil
+	}
+	p.nextToken()
+	expr.Alternative = p.parseExpression(LOWEST)
+	if expr.Alternative == nil {
+		return nil
+	}
+	return expr
+}
+
+// parsePrimary parses a primary expression followed by any calls or index accesses.
 func (p *Parser) parsePrimary() Expression {
+	return p.parsePostfix(p.parseOperand())
+}
+
+// parsePostfix applies any calls or index accesses that follow left.
+func (p *Parser) parsePostfix(left Expression) Expression {
+	for left != nil {
+		switch p.curToken.Type {
+		case lexer.LPAREN:
+			left = p.parseCallExpression(left)
+		case lexer.LBRACKET, lexer.SAFE_IDX:
+			left = p.parseIndexExpression(left)
+		default:
+			return left
+		}
+	}
+	return nil
+}
+
+// parseOperand parses a single operand (number, string, boolean, identifier, array, or hash).
+func (p *Parser) parseOperand() Expression {
 	switch p.curToken.Type {
 	case lexer.INT:
-		value, err := strconv.ParseInt(p.curToken.Literal, 10, 64)
-		if err != nil {
-			fmt.Printf("Error at line %d, col %d: Invalid number %s // Numbers too hard for you, huh?\n", p.curToken.Line, p.curToken.Column, p.curToken.Literal)
+		value, ok := p.parseInt()
+		if !ok {
 			return nil
 		}
 		result := &NumberLiteral{Token: p.curToken, Value: value}
 		p.nextToken()
 		return result
+	case lexer.FLOAT:
+		value, ok := p.parseFloat()
+		if !ok {
+			return nil
+		}
+		result := &FloatLiteral{Token: p.curToken, Value: value}
+		p.nextToken()
+		return result
 	case lexer.STRING:
 		result := &StringLiteral{Token: p.curToken, Value: p.curToken.Literal}
 		p.nextToken()
@@ -366,10 +1240,170 @@ func (p *Parser) parsePrimary() Expression {
 		result := &Identifier{Token: p.curToken, Value: p.curToken.Literal}
 		p.nextToken()
 		return result
+	case lexer.YAS, lexer.NAH:
+		result := &BooleanLiteral{Token: p.curToken, Value: p.curToken.Type == lexer.YAS}
+		p.nextToken()
+		return result
+	case lexer.KHALI:
+		result := &NullLiteral{Token: p.curToken}
+		p.nextToken()
+		return result
+	case lexer.LBRACKET:
+		array := &ArrayLiteral{Token: p.curToken}
+		array.Elements = p.parseExpressionList(lexer.RBRACKET)
+		if array.Elements == nil {
+			return nil
+		}
+		return array
+	case lexer.LBRACE:
+		return p.parseHashLiteral()
+	case lexer.LPAREN:
+		return p.parseGroupedExpression()
 	default:
-		fmt.Printf("Error at line %d, col %d: Expected number, string, or identifier, got %s // What even is this, genius?\n", p.curToken.Line, p.curToken.Column, p.curToken.Type)
+		p.errorf("Expected number, string, or identifier, got %s // What even is this, genius?", p.curToken.Type)
+		return nil
+	}
+}
+
+// parseInt converts the current INT token, reporting it if it is invalid.
+func (p *Parser) parseInt() (int64, bool) {
+	literal, ok := stripSeparators(p.curToken.Literal)
+	value, err := strconv.ParseInt(literal, 10, 64)
+	if !ok || err != nil {
+		p.errorf("Invalid number %s // Numbers too hard for you, huh?", p.curToken.Literal)
+		return 0, false
+	}
+	return value, true
+}
+
+// parseFloat converts the current FLOAT token, reporting it if it is invalid.
+func (p *Parser) parseFloat() (float64, bool) {
+	literal, ok := stripSeparators(p.curToken.Literal)
+	value, err := strconv.ParseFloat(literal, 64)
+	if !ok || err != nil {
+		p.errorf("Invalid number %s // Numbers too hard for you, huh?", p.curToken.Literal)
+		return 0, false
+	}
+	return value, true
+}
+
+// stripSeparators removes the "_" digit separators from a number literal
+// (e.g., 1_000.5 becomes 1000.5). It reports false if a separator is not
+// between two digits, as in 1__0, 10_, 1_.5 or 1._5.
+func stripSeparators(literal string) (string, bool) {
+	if !strings.Contains(literal, "_") {
+		return literal, true
+	}
+	for idx := range len(literal) {
+		if literal[idx] != '_' {
+			continue
+		}
+		if idx == 0 || idx == len(literal)-1 || !isDigit(literal[idx-1]) || !isDigit(literal[idx+1]) {
+			return "", false
+		}
+	}
+	return strings.ReplaceAll(literal, "_", ""), true
+}
+
+func isDigit(ch byte) bool {
+	return '0' <= ch && ch <= '9'
+}
+
+// parseHashLiteral parses a hash literal (e.g., {"a": 1, "b": 2}).
+func (p *Parser) parseHashLiteral() Expression {
+	hash := &HashLiteral{Token: p.curToken}
+	p.nextToken()
+	for p.curToken.Type != lexer.RBRACE {
+		key := p.parseExpression(LOWEST)
+		if key == nil {
+			return nil
+		}
+		if p.curToken.Type != lexer.COLON {
+			p.errorf("Expected : after hash key, got %s // Keys need values, genius!", p.curToken.Type)
+			return nil
+		}
+		p.nextToken()
+		value := p.parseExpression(LOWEST)
+		if value == nil {
+			return nil
+		}
+		hash.Keys = append(hash.Keys, key)
+		hash.Values = append(hash.Values, value)
+		if p.curToken.Type == lexer.COMMA {
+			p.nextToken()
+		} else if p.curToken.Type != lexer.RBRACE {
+			p.errorf("Expected , or } in hash, got %s // Close your hash, you walnut!", p.curToken.Type)
+			return nil
+		}
+	}
+	p.nextToken() // Skip closing brace
+	return hash
+}
+
+// parseGroupedExpression parses an expression in parentheses (e.g., (2 + 3) * 4)
+// and returns the inner expression; the AST needs no node for the grouping.
+func (p *Parser) parseGroupedExpression() Expression {
+	open := p.curToken
+	p.nextToken()
+	inner := p.parseExpression(LOWEST)
+	if inner == nil {
 		return nil
 	}
+	if p.curToken.Type != lexer.RPAREN {
+		p.errorf("Expected ) to close ( opened at line %d, col %d, got %s // Close your parens, loser!", open.Line, open.Column, p.curToken.Type)
+		return nil
+	}
+	p.nextToken() // Skip ')'
+	return inner
+}
+
+// parseCallExpression parses the argument list of a call (e.g., has_key(h, "a")).
+func (p *Parser) parseCallExpression(function Expression) Expression {
+	call := &CallExpression{Token: p.curToken, Function: function}
+	call.Arguments = p.parseExpressionList(lexer.RPAREN)
+	if call.Arguments == nil {
+		return nil
+	}
+	return call
+}
+
+// parseExpressionList parses comma-separated expressions from the current
+// opening token up to and including end. It returns nil on error and an
+// empty (non-nil) slice for an empty list.
+func (p *Parser) parseExpressionList(end lexer.TokenType) []Expression {
+	list := []Expression{}
+	p.nextToken()
+	for p.curToken.Type != end {
+		expr := p.parseExpression(LOWEST)
+		if expr == nil {
+			return nil
+		}
+		list = append(list, expr)
+		if p.curToken.Type == lexer.COMMA {
+			p.nextToken()
+		} else if p.curToken.Type != end {
+			p.errorf("Expected , or %s in list, got %s // Close your lists, loser!", end, p.curToken.Type)
+			return nil
+		}
+	}
+	p.nextToken() // Skip closing token
+	return list
+}
+
+// parseIndexExpression parses an index access (e.g., h["a"]).
+func (p *Parser) parseIndexExpression(left Expression) Expression {
+	index := &IndexExpression{Token: p.curToken, Left: left, Safe: p.curToken.Type == lexer.SAFE_IDX}
+	p.nextToken()
+	index.Index = p.parseExpression(LOWEST)
+	if index.Index == nil {
+		return nil
+	}
+	if p.curToken.Type != lexer.RBRACKET {
+		p.errorf("Expected ] after index, got %s // Close your brackets, loser!", p.curToken.Type)
+		return nil
+	}
+	p.nextToken() // Skip closing bracket
+	return index
 }
 
 // getCurrentPrecedence returns the precedence of the current token.
@@ -392,7 +1426,53 @@ func (p *Parser) getPrecedence(tokenType lexer.TokenType) int {
 func isOperator(tokenType lexer.TokenType) bool {
 	return tokenType == lexer.PLUS || tokenType == lexer.MINUS ||
 		tokenType == lexer.ASTERISK || tokenType == lexer.SLASH ||
+		tokenType == lexer.PERCENT ||
 		tokenType == lexer.EQ || tokenType == lexer.NOT_EQ ||
 		tokenType == lexer.LT || tokenType == lexer.GT ||
-		tokenType == lexer.LE || tokenType == lexer.GE
+		tokenType == lexer.LE || tokenType == lexer.GE ||
+		tokenType == lexer.AND || tokenType == lexer.OR
+}
+
+// startsOperand reports whether a token of this type begins an operand
+// (a literal or identifier).
+func startsOperand(tokenType lexer.TokenType) bool {
+	switch tokenType {
+	case lexer.IDENT, lexer.INT, lexer.FLOAT, lexer.STRING, lexer.YAS, lexer.NAH, lexer.KHALI:
+		return true
+	}
+	return false
+}
+
+// suggestKeyword returns the keyword closest to ident by edit distance, or ""
+// if none is within two edits.
+func suggestKeyword(ident string) string {
+	best, bestDistance := "", 3
+	for _, keyword := range lexer.Keywords() {
+		if d := levenshtein(ident, keyword); d < bestDistance {
+			best, bestDistance = keyword, d
+		}
+	}
+	return best
+}
+
+// levenshtein returns the minimum number of single-character insertions,
+// deletions, or substitutions needed to turn a into b.
+func levenshtein(a, b string) int {
+	prev := make([]int, len(b)+1)
+	curr := make([]int, len(b)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+	for i := 1; i <= len(a); i++ {
+		curr[0] = i
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+			curr[j] = min(prev[j]+1, curr[j-1]+1, prev[j-1]+cost)
+		}
+		prev, curr = curr, prev
+	}
+	return prev[len(b)]
 }
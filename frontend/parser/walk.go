@@ -0,0 +1,99 @@
+package parser
+
+// Walk traverses the tree rooted at node in source order. It calls visit for
+// each node before its children and only descends into the children if visit
+// returns true. Missing optional parts, like an if without magar, are skipped.
+func Walk(node Node, visit func(Node) bool) {
+	if isNilNode(node) || !visit(node) {
+		return
+	}
+	switch n := node.(type) {
+	case *Program:
+		for _, stmt := range n.Statements {
+			Walk(stmt, visit)
+		}
+	case *PrintStatement:
+		for _, value := range n.Values {
+			Walk(value, visit)
+		}
+	case *AssignmentStatement:
+		if n.Names != nil {
+			for _, name := range n.Names {
+				Walk(name, visit)
+			}
+		} else {
+			Walk(n.Name, visit)
+		}
+		Walk(n.Value, visit)
+	case *ExpressionStatement:
+		Walk(n.Expression, visit)
+	case *IfStatement:
+		Walk(n.Condition, visit)
+		Walk(n.Consequence, visit)
+		Walk(n.Alternative, visit)
+	case *TryStatement:
+		Walk(n.Body, visit)
+		Walk(n.Param, visit)
+		Walk(n.Handler, visit)
+		Walk(n.Finally, visit)
+	case *WhileStatement:
+		Walk(n.Condition, visit)
+		Walk(n.Count, visit)
+		Walk(n.Body, visit)
+	case *FunctionStatement:
+		Walk(n.Name, visit)
+		for _, param := range n.Parameters {
+			Walk(param, visit)
+		}
+		Walk(n.Body, visit)
+	case *ReturnStatement:
+		Walk(n.Value, visit)
+	case *BlockStatement:
+		for _, stmt := range n.Statements {
+			Walk(stmt, visit)
+		}
+	case *ArrayLiteral:
+		for _, el := range n.Elements {
+			Walk(el, visit)
+		}
+	case *HashLiteral:
+		for idx := range n.Keys {
+			Walk(n.Keys[idx], visit)
+			Walk(n.Values[idx], visit)
+		}
+	case *CallExpression:
+		Walk(n.Function, visit)
+		for _, arg := range n.Arguments {
+			Walk(arg, visit)
+		}
+	case *IndexExpression:
+		Walk(n.Left, visit)
+		Walk(n.Index, visit)
+	case *BinaryExpression:
+		Walk(n.Left, visit)
+		Walk(n.Right, visit)
+	case *PrefixExpression:
+		Walk(n.Right, visit)
+	case *ConditionalExpression:
+		Walk(n.Condition, visit)
+		Walk(n.Consequence, visit)
+		Walk(n.Alternative, visit)
+	}
+	// BranchStatement, PassStatement, Identifier and the literals have no children.
+}
+
+// isNilNode reports whether node is nil, including a typed nil pointer such
+// as the *BlockStatement of an if without magar.
+func isNilNode(node Node) bool {
+	switch n := node.(type) {
+	case nil:
+		return true
+	case *Program:
+		return n == nil
+	case *BlockStatement:
+		return n == nil
+	case *Identifier:
+		return n == nil
+	}
+	return false
+}
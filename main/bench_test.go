@@ -0,0 +1,65 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/salillakra/npp/frontend/lexer"
+	"github.com/salillakra/npp/frontend/parser"
+)
+
+// benchmarkProgram generates a representative program with n repetitions of
+// declarations, arithmetic, collections, calls, conditionals and error handling.
+func benchmarkProgram(n int) string {
+	var sb strings.Builder
+	for idx := range n {
+		fmt.Fprintf(&sb, "// block %d\n", idx)
+		fmt.Fprintf(&sb, "sun counter_%d = %d * 3 + 7 %% 4 - 2;\n", idx, idx)
+		fmt.Fprintf(&sb, "sun ratio_%d = %d.5 / 2.0;\n", idx, idx)
+		fmt.Fprintf(&sb, "sun name_%d = \"item number %d\";\n", idx, idx)
+		fmt.Fprintf(&sb, "sun items_%d = [counter_%d, ratio_%d, name_%d, yas];\n", idx, idx, idx, idx)
+		fmt.Fprintf(&sb, "sun lookup_%d = {\"count\": counter_%d, \"name\": name_%d};\n", idx, idx, idx)
+		fmt.Fprintf(&sb, "agar len(items_%d) >= 4 {\n    suna lookup_%d[\"name\"];\n} magar {\n    suna replace(name_%d, \"item\", \"thing\");\n}\n", idx, idx, idx)
+		fmt.Fprintf(&sb, "koshish {\n    suna counter_%d / 0;\n} pakad (e) {\n    suna e;\n}\n", idx)
+	}
+	return sb.String()
+}
+
+var benchmarkSource = benchmarkProgram(500)
+
+func BenchmarkLexer(b *testing.B) {
+	b.SetBytes(int64(len(benchmarkSource)))
+	b.ReportAllocs()
+	for b.Loop() {
+		l := lexer.New(benchmarkSource)
+		for tok := l.NextToken(); tok.Type != lexer.EOF; tok = l.NextToken() {
+		}
+	}
+}
+
+func BenchmarkParser(b *testing.B) {
+	b.SetBytes(int64(len(benchmarkSource)))
+	b.ReportAllocs()
+	for b.Loop() {
+		p := parser.New(lexer.New(benchmarkSource), false)
+		p.ParseProgram()
+		if len(p.Errors()) > 0 {
+			b.Fatalf("benchmark fixture has syntax errors: %v", p.Errors()[0])
+		}
+	}
+}
+
+// BenchmarkParserScaling parses fixtures of growing size; time per op should
+// grow linearly with the size of the program.
+func BenchmarkParserScaling(b *testing.B) {
+	for _, n := range []int{10, 100, 1000} {
+		source := benchmarkProgram(n)
+		b.Run(fmt.Sprintf("blocks=%d", n), func(b *testing.B) {
+			b.SetBytes(int64(len(source)))
+			for b.Loop() {
+				parser.New(lexer.New(source), false).ParseProgram()
+			}
+		})
+	}
+}
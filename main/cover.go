@@ -0,0 +1,129 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+
+	core "github.com/salillakra/npp/core/interpreter"
+	"github.com/salillakra/npp/frontend/parser"
+)
+
+// coverage records which statements of a program ran. Statements are keyed
+// by themselves rather than by position, since two files can each have a
+// statement at the same line and column.
+type coverage struct {
+	files      []string           // source files, in the order they run
+	statements []parser.Statement // every statement, including nested ones
+	file       map[parser.Statement]string
+	executed   map[parser.Statement]bool
+}
+
+// cover runs the program in filePaths and prints a per-line coverage report,
+// grouped by file when there is more than one.
+func cover(filePaths []string) {
+	c := &coverage{files: filePaths, file: make(map[parser.Statement]string), executed: make(map[parser.Statement]bool)}
+	program := &parser.Program{Statements: []parser.Statement{}}
+	for _, filePath := range filePaths {
+		part := loadProgram([]string{filePath})
+		if part == nil {
+			return
+		}
+		c.collect(filePath, part.Statements)
+		program.Statements = append(program.Statements, part.Statements...)
+	}
+	i := core.New()
+	i.OnStatement = c.onStatement
+	i.Interpret(program)
+	c.report()
+}
+
+// collect adds stmts from filePath and the statements nested in their blocks.
+func (c *coverage) collect(filePath string, stmts []parser.Statement) {
+	for _, stmt := range stmts {
+		if stmt == nil {
+			continue
+		}
+		c.statements = append(c.statements, stmt)
+		c.file[stmt] = filePath
+		switch s := stmt.(type) {
+		case *parser.IfStatement:
+			c.collectBlock(filePath, s.Consequence)
+			c.collectBlock(filePath, s.Alternative)
+		case *parser.WhileStatement:
+			c.collectBlock(filePath, s.Body)
+		case *parser.BlockStatement:
+			c.collectBlock(filePath, s)
+		case *parser.TryStatement:
+			c.collectBlock(filePath, s.Body)
+			c.collectBlock(filePath, s.Handler)
+			c.collectBlock(filePath, s.Finally)
+		case *parser.FunctionStatement:
+			c.collectBlock(filePath, s.Body)
+		}
+	}
+}
+
+func (c *coverage) collectBlock(filePath string, block *parser.BlockStatement) {
+	if block != nil {
+		c.collect(filePath, block.Statements)
+	}
+}
+
+// onStatement is the interpreter hook that marks stmt as executed.
+func (c *coverage) onStatement(stmt parser.Statement) {
+	c.executed[stmt] = true
+}
+
+// report prints each line holding statements as covered, partial (only some
+// of its statements ran) or NOT covered, followed by a total. With several
+// files, each line is prefixed with the file it belongs to.
+func (c *coverage) report() {
+	type lineCount struct{ total, ran int }
+	lines := make(map[string]map[int]*lineCount)
+	ran := 0
+	for _, stmt := range c.statements {
+		file, line := c.file[stmt], stmt.Token().Line
+		if lines[file] == nil {
+			lines[file] = make(map[int]*lineCount)
+		}
+		count, ok := lines[file][line]
+		if !ok {
+			count = &lineCount{}
+			lines[file][line] = count
+		}
+		count.total++
+		if c.executed[stmt] {
+			count.ran++
+			ran++
+		}
+	}
+
+	fmt.Println("----- coverage -----")
+	for _, file := range c.files {
+		prefix := ""
+		if len(c.files) > 1 {
+			prefix = file + ": "
+		}
+		numbers := make([]int, 0, len(lines[file]))
+		for line := range lines[file] {
+			numbers = append(numbers, line)
+		}
+		sort.Ints(numbers)
+		for _, line := range numbers {
+			count := lines[file][line]
+			switch count.ran {
+			case count.total:
+				fmt.Printf("%sline %d: covered\n", prefix, line)
+			case 0:
+				fmt.Printf("%sline %d: NOT covered\n", prefix, line)
+			default:
+				fmt.Printf("%sline %d: partial (%d/%d statements)\n", prefix, line, count.ran, count.total)
+			}
+		}
+	}
+	percent := 100.0
+	if len(c.statements) > 0 {
+		percent = float64(ran) * 100 / float64(len(c.statements))
+	}
+	fmt.Printf("%d/%d statements covered (%.1f%%)\n", ran, len(c.statements), percent)
+}
@@ -0,0 +1,65 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strings"
+
+	core "github.com/salillakra/npp/core/interpreter"
+	"github.com/salillakra/npp/frontend/parser"
+)
+
+const debugPrompt = "(debug) "
+
+// debugger pauses before each statement, shows where the program is and
+// waits for a command: step (or an empty line) runs the statement, continue
+// runs the rest of the program without stopping, and vars lists the
+// variables declared so far. Running out of input also continues.
+type debugger struct {
+	in       *bufio.Scanner
+	i        *core.Interpreter
+	stepping bool
+}
+
+// debug runs the program in filePaths under the step debugger, reading
+// commands from in.
+func debug(filePaths []string, in io.Reader) {
+	program := loadProgram(filePaths)
+	if program == nil {
+		return
+	}
+	d := &debugger{in: bufio.NewScanner(in), i: core.New(), stepping: true}
+	d.i.OnStatement = d.onStatement
+	d.i.Interpret(program)
+}
+
+// onStatement is the interpreter hook that pauses before stmt.
+func (d *debugger) onStatement(stmt parser.Statement) {
+	if !d.stepping {
+		return
+	}
+	fmt.Printf("line %d: %s\n", stmt.Token().Line, stmt.String())
+	for {
+		fmt.Print(debugPrompt)
+		if !d.in.Scan() {
+			fmt.Println()
+			d.stepping = false
+			return
+		}
+		switch strings.TrimSpace(d.in.Text()) {
+		case "", "s", "step":
+			return
+		case "c", "continue":
+			d.stepping = false
+			return
+		case "v", "vars":
+			for _, name := range d.i.Vars() {
+				value, _ := d.i.Lookup(name)
+				fmt.Printf("%s = %s\n", name, value.String())
+			}
+		default:
+			fmt.Println("Unknown command, try step (s), continue (c) or vars (v)")
+		}
+	}
+}
@@ -0,0 +1,182 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/salillakra/npp/frontend/parser"
+)
+
+// lintWarning is a suspicious but legal piece of code.
+type lintWarning struct {
+	Line, Column int
+	Message      string
+}
+
+// lint parses every file and prints warnings for suspicious code without
+// running it. It returns 1 if any file is invalid or has warnings, and 0
+// otherwise.
+func lint(filePaths []string) int {
+	status := 0
+	for _, filePath := range filePaths {
+		if filepath.Ext(filePath) != ".npp" {
+			fmt.Println("Invalid file type. Please provide a .npp file.")
+			return 1
+		}
+
+		dat, err := os.ReadFile(filePath)
+		if err != nil {
+			fmt.Println(err)
+			return 1
+		}
+
+		p := newParser(string(dat))
+		program := p.ParseProgram()
+		if errs := p.Errors(); len(errs) > 0 {
+			fmt.Printf("%s: %d syntax error(s)\n", filePath, len(errs))
+			status = 1
+			continue
+		}
+		for _, w := range lintProgram(program) {
+			fmt.Printf("%s:%d:%d: warning: %s\n", filePath, w.Line, w.Column, w.Message)
+			status = 1
+		}
+	}
+	return status
+}
+
+// linter walks a program in source order, remembering which variables were
+// last assigned the result of a comparison.
+type linter struct {
+	comparisons map[string]bool
+	warnings    []lintWarning
+}
+
+// lintProgram returns the warnings for program in source order.
+func lintProgram(program *parser.Program) []lintWarning {
+	l := &linter{comparisons: make(map[string]bool)}
+	l.statements(program.Statements)
+	return l.warnings
+}
+
+func (l *linter) statements(stmts []parser.Statement) {
+	for _, stmt := range stmts {
+		switch s := stmt.(type) {
+		case *parser.AssignmentStatement:
+			l.expression(s.Value)
+			if s.Names != nil {
+				// Unpacked values come from an array, never straight from a comparison.
+				for _, name := range s.Names {
+					l.comparisons[name.Value] = false
+				}
+			} else {
+				l.comparisons[s.Name.Value] = isComparison(s.Value)
+			}
+		case *parser.PrintStatement:
+			for _, value := range s.Values {
+				l.expression(value)
+			}
+		case *parser.ExpressionStatement:
+			l.expression(s.Expression)
+		case *parser.IfStatement:
+			l.expression(s.Condition)
+			l.block(s.Consequence)
+			l.block(s.Alternative)
+		case *parser.WhileStatement:
+			l.expression(s.Condition)
+			l.expression(s.Count)
+			l.block(s.Body)
+		case *parser.BlockStatement:
+			l.block(s)
+		case *parser.TryStatement:
+			l.block(s.Body)
+			l.block(s.Handler)
+			l.block(s.Finally)
+		case *parser.FunctionStatement:
+			// Parameters hold whatever the caller passes, never a known comparison.
+			for _, param := range s.Parameters {
+				l.comparisons[param.Value] = false
+			}
+			l.block(s.Body)
+		case *parser.ReturnStatement:
+			l.expression(s.Value)
+		}
+	}
+}
+
+func (l *linter) block(block *parser.BlockStatement) {
+	if block != nil {
+		l.statements(block.Statements)
+	}
+}
+
+func (l *linter) expression(expr parser.Expression) {
+	switch e := expr.(type) {
+	case *parser.BinaryExpression:
+		if isArithmetic(e.Operator) {
+			l.checkOperand(e.Left, e.Operator)
+			l.checkOperand(e.Right, e.Operator)
+		}
+		l.expression(e.Left)
+		l.expression(e.Right)
+	case *parser.PrefixExpression:
+		l.expression(e.Right)
+	case *parser.ConditionalExpression:
+		l.expression(e.Condition)
+		l.expression(e.Consequence)
+		l.expression(e.Alternative)
+	case *parser.CallExpression:
+		l.expression(e.Function)
+		for _, arg := range e.Arguments {
+			l.expression(arg)
+		}
+	case *parser.IndexExpression:
+		l.expression(e.Left)
+		l.expression(e.Index)
+	case *parser.ArrayLiteral:
+		for _, el := range e.Elements {
+			l.expression(el)
+		}
+	case *parser.HashLiteral:
+		for idx := range e.Keys {
+			l.expression(e.Keys[idx])
+			l.expression(e.Values[idx])
+		}
+	}
+}
+
+// checkOperand warns when an arithmetic operand is a variable holding a
+// comparison result, as in sun x = a > b; suna x + 1;.
+func (l *linter) checkOperand(operand parser.Expression, op string) {
+	ident, ok := operand.(*parser.Identifier)
+	if !ok || !l.comparisons[ident.Value] {
+		return
+	}
+	l.warnings = append(l.warnings, lintWarning{
+		Line:    ident.Token.Line,
+		Column:  ident.Token.Column,
+		Message: fmt.Sprintf("%s holds a comparison result (yas/nah), so %s on it is probably a mistake", ident.Value, op),
+	})
+}
+
+// isComparison reports whether expr directly produces a comparison result.
+func isComparison(expr parser.Expression) bool {
+	be, ok := expr.(*parser.BinaryExpression)
+	if !ok {
+		return false
+	}
+	switch be.Operator {
+	case "==", "!=", "<", ">", "<=", ">=":
+		return true
+	}
+	return false
+}
+
+func isArithmetic(op string) bool {
+	switch op {
+	case "+", "-", "*", "/", "%":
+		return true
+	}
+	return false
+}
@@ -1,40 +1,170 @@
 package main
 
 import (
+	"flag"
 	"fmt"
 	"os"
 	"path/filepath"
+	"time"
 
 	core "github.com/salillakra/npp/core/interpreter"
 	"github.com/salillakra/npp/frontend/lexer"
 	"github.com/salillakra/npp/frontend/parser"
 )
 
+// strictSemicolons is set by -strict-semicolons and applies to every file parsed.
+var strictSemicolons bool
+
 func main() {
+	watchMode := flag.Bool("watch", false, "re-run the program whenever a source file changes")
+	checkOnly := flag.Bool("check", false, "parse the files and report syntax errors without running them")
+	debugMode := flag.Bool("debug", false, "pause before each statement and step through the program")
+	coverMode := flag.Bool("cover", false, "report which lines ran after the program finishes")
+	lintOnly := flag.Bool("lint", false, "report suspicious code without running it")
+	fmtOnly := flag.Bool("fmt", false, "print the files in the standard layout, keeping the comments above statements")
+	testMode := flag.Bool("test", false, "run each matching .npp file in the given directory as a test and report pass/fail")
+	testPattern := flag.String("test-pattern", "*.npp", "file name pattern -test runs (e.g. *_test.npp)")
+	flag.BoolVar(&strictSemicolons, "strict-semicolons", false, "require ; between statements on the same line")
+	flag.Parse()
 
-	var filePath string
-	if len(os.Args) > 1 {
-		filePath = os.Args[1]
-	} else {
-		fmt.Println("Please provide a file path as an argument.")
+	if flag.NArg() < 1 {
+		fmt.Println("No file given, starting the REPL (:types toggles type display, Ctrl+D exits).")
+		repl(os.Stdin)
 		return
 	}
 
-	fileExtension := filepath.Ext(filePath)
+	if *checkOnly {
+		os.Exit(check(flag.Args()))
+	}
 
-	if fileExtension != ".npp" {
-		fmt.Println("Invalid file type. Please provide a .npp file.")
+	if *lintOnly {
+		os.Exit(lint(flag.Args()))
+	}
+
+	if *fmtOnly {
+		os.Exit(format(flag.Args()))
+	}
+
+	if *testMode {
+		os.Exit(runSuite(flag.Arg(0), *testPattern))
+	}
+
+	if *debugMode {
+		debug(flag.Args(), os.Stdin)
 		return
 	}
 
-	dat, err := os.ReadFile(filePath)
-	if err != nil {
-		panic(err)
+	if *coverMode {
+		cover(flag.Args())
+		return
 	}
 
-	l := lexer.New(string(dat))
-	p := parser.New(l, false) // Disabled debug output
-	program := p.ParseProgram()
+	if *watchMode {
+		w, err := newPollWatcher(flag.Args(), 500*time.Millisecond)
+		if err != nil {
+			panic(err)
+		}
+		watch(flag.Args(), w)
+		return
+	}
+
+	run(flag.Args())
+}
+
+// run parses every file in order and interprets their statements as one
+// program, so later files see variables declared by earlier ones.
+func run(filePaths []string) {
+	program := loadProgram(filePaths)
+	if program == nil {
+		return
+	}
 	i := core.New()
 	i.Interpret(program)
 }
+
+// loadProgram parses every file in order into a single program. It returns
+// nil if a file is not an .npp file.
+func loadProgram(filePaths []string) *parser.Program {
+	program := &parser.Program{Statements: []parser.Statement{}}
+	for _, filePath := range filePaths {
+		fileExtension := filepath.Ext(filePath)
+
+		if fileExtension != ".npp" {
+			fmt.Println("Invalid file type. Please provide a .npp file.")
+			return nil
+		}
+
+		dat, err := os.ReadFile(filePath)
+		if err != nil {
+			panic(err)
+		}
+
+		p := newParser(string(dat))
+		program.Statements = append(program.Statements, p.ParseProgram().Statements...)
+	}
+	return program
+}
+
+// newParser returns a parser for a source file, honoring -strict-semicolons.
+func newParser(src string) *parser.Parser {
+	p := parser.New(lexer.New(src), false) // Disabled debug output
+	p.StrictSemicolons = strictSemicolons
+	return p
+}
+
+// check lexes and parses every file without interpreting it. It returns 1 if
+// any file is invalid or has syntax errors, and 0 otherwise.
+func check(filePaths []string) int {
+	status := 0
+	for _, filePath := range filePaths {
+		if filepath.Ext(filePath) != ".npp" {
+			fmt.Println("Invalid file type. Please provide a .npp file.")
+			return 1
+		}
+
+		dat, err := os.ReadFile(filePath)
+		if err != nil {
+			fmt.Println(err)
+			return 1
+		}
+
+		p := newParser(string(dat))
+		p.ParseProgram()
+		if errs := p.Errors(); len(errs) > 0 {
+			fmt.Printf("%s: %d syntax error(s)\n", filePath, len(errs))
+			status = 1
+		}
+	}
+	return status
+}
+
+// format prints every file in the layout parser.Format gives it, without
+// running it. It returns 1 if any file is invalid or has syntax errors, and
+// 0 otherwise.
+func format(filePaths []string) int {
+	status := 0
+	for _, filePath := range filePaths {
+		if filepath.Ext(filePath) != ".npp" {
+			fmt.Println("Invalid file type. Please provide a .npp file.")
+			return 1
+		}
+
+		dat, err := os.ReadFile(filePath)
+		if err != nil {
+			fmt.Println(err)
+			return 1
+		}
+
+		out, errs := parser.Format(string(dat))
+		if len(errs) > 0 {
+			for _, err := range errs {
+				fmt.Println(err)
+			}
+			fmt.Printf("%s: %d syntax error(s)\n", filePath, len(errs))
+			status = 1
+			continue
+		}
+		fmt.Print(out)
+	}
+	return status
+}
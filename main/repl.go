@@ -0,0 +1,71 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strings"
+
+	core "github.com/salillakra/npp/core/interpreter"
+	"github.com/salillakra/npp/frontend/lexer"
+	"github.com/salillakra/npp/frontend/parser"
+)
+
+const replPrompt = ">> "
+
+// repl reads one line at a time from in and runs it against a shared
+// interpreter, echoing the value of expression statements. The :types
+// command toggles printing each value's type next to it, and :vars lists
+// the variables declared so far.
+func repl(in io.Reader) {
+	scanner := bufio.NewScanner(in)
+	i := core.New()
+	showTypes := false
+	for {
+		fmt.Print(replPrompt)
+		if !scanner.Scan() {
+			fmt.Println()
+			return
+		}
+		line := strings.TrimSpace(scanner.Text())
+		switch line {
+		case "":
+			continue
+		case ":vars":
+			for _, name := range i.Vars() {
+				value, _ := i.Lookup(name)
+				fmt.Printf("%s = %s\n", name, value.String())
+			}
+			continue
+		case ":types":
+			showTypes = !showTypes
+			if showTypes {
+				fmt.Println("types on")
+			} else {
+				fmt.Println("types off")
+			}
+			continue
+		}
+
+		p := parser.New(lexer.New(line), false)
+		program := p.ParseProgram()
+		if len(p.Errors()) > 0 {
+			continue
+		}
+		for _, stmt := range program.Statements {
+			result := i.Eval(stmt)
+			if result == nil {
+				continue
+			}
+			if core.IsError(result) {
+				fmt.Println(result.String())
+				break
+			}
+			if showTypes {
+				fmt.Printf("=> %s : %s\n", result.String(), core.TypeName(result))
+			} else {
+				fmt.Printf("=> %s\n", result.String())
+			}
+		}
+	}
+}
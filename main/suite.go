@@ -0,0 +1,88 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	core "github.com/salillakra/npp/core/interpreter"
+)
+
+// runSuite runs every .npp file in dir whose name matches pattern as its own
+// test script, printing PASS or FAIL for each and then a summary. A script
+// fails if it has syntax errors or stops on an uncaught error, such as a
+// failed assert. It returns 1 if any script failed and 0 otherwise.
+func runSuite(dir, pattern string) int {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		fmt.Println(err)
+		return 1
+	}
+	var paths []string
+	for _, entry := range entries {
+		if entry.IsDir() || filepath.Ext(entry.Name()) != ".npp" {
+			continue
+		}
+		matched, err := filepath.Match(pattern, entry.Name())
+		if err != nil {
+			fmt.Printf("Invalid test pattern %q: %v\n", pattern, err)
+			return 1
+		}
+		if matched {
+			paths = append(paths, filepath.Join(dir, entry.Name()))
+		}
+	}
+	if len(paths) == 0 {
+		fmt.Printf("No files matching %s in %s\n", pattern, dir)
+		return 0
+	}
+
+	failed := 0
+	for _, path := range paths {
+		report, ok := runTestScript(path)
+		if ok {
+			fmt.Printf("PASS %s\n", path)
+			continue
+		}
+		failed++
+		fmt.Printf("FAIL %s\n", path)
+		for _, line := range strings.Split(strings.TrimSuffix(report, "\n"), "\n") {
+			fmt.Printf("    %s\n", line)
+		}
+	}
+	fmt.Printf("%d passed, %d failed\n", len(paths)-failed, failed)
+	if failed > 0 {
+		return 1
+	}
+	return 0
+}
+
+// runTestScript runs the file at path in a fresh interpreter and reports
+// whether it passed. On failure, report holds what the script printed
+// followed by the error that stopped it.
+func runTestScript(path string) (report string, ok bool) {
+	dat, err := os.ReadFile(path)
+	if err != nil {
+		return err.Error(), false
+	}
+	p := newParser(string(dat))
+	p.Quiet = true
+	program := p.ParseProgram()
+	if errs := p.Errors(); len(errs) > 0 {
+		lines := make([]string, len(errs))
+		for idx, err := range errs {
+			lines[idx] = err.Error()
+		}
+		return strings.Join(lines, "\n"), false
+	}
+
+	i := core.New()
+	i.CaptureOutput()
+	for _, stmt := range program.Statements {
+		if result := i.Eval(stmt); core.IsError(result) {
+			return i.Output() + result.String(), false
+		}
+	}
+	return "", true
+}
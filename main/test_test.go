@@ -1,8 +1,17 @@
 package main
 
 import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
 	"os"
+	"path/filepath"
+	"slices"
+	"strings"
 	"testing"
+	"testing/iotest"
+	"time"
 
 	core "github.com/salillakra/npp/core/interpreter"
 	"github.com/salillakra/npp/frontend/lexer"
@@ -43,3 +52,2814 @@ func TestCodeNPP(t *testing.T) {
 		t.Errorf("Unexpected output.\nGot:\n%q\nWant:%q", output, expected)
 	}
 }
+
+// captureStdout runs fn and returns everything it wrote to stdout.
+func captureStdout(t *testing.T, fn func()) string {
+	t.Helper()
+	oldStdout := os.Stdout
+	r, w, err := os.Pipe()
+	if err != nil {
+		t.Fatalf("Failed to create pipe: %v", err)
+	}
+	os.Stdout = w
+	done := make(chan string)
+	go func() {
+		out, _ := io.ReadAll(r)
+		done <- string(out)
+	}()
+
+	fn()
+
+	w.Close()
+	os.Stdout = oldStdout
+	return <-done
+}
+
+// runNPP lexes, parses and interprets code, returning everything written to stdout.
+func runNPP(t *testing.T, code string) string {
+	t.Helper()
+	return captureStdout(t, func() {
+		l := lexer.New(code)
+		p := parser.New(l, false)
+		program := p.ParseProgram()
+		i := core.New()
+		i.Interpret(program)
+	})
+}
+
+// runMalformed runs code that has syntax errors the way main does, carrying
+// on to interpret it, and fails the test if that panics. It returns what was
+// printed.
+func runMalformed(t *testing.T, code string) string {
+	t.Helper()
+	return captureStdout(t, func() {
+		defer func() {
+			if r := recover(); r != nil {
+				t.Errorf("%s: panicked: %v", code, r)
+			}
+		}()
+		i := core.New()
+		i.Interpret(parser.New(lexer.New(code), false).ParseProgram())
+	})
+}
+
+// checkOnlyParseErrors fails the test unless output is nothing but syntax
+// errors, the first containing want.
+func checkOnlyParseErrors(t *testing.T, code, output, want string) {
+	t.Helper()
+	lines := strings.Split(strings.TrimSuffix(output, "\n"), "\n")
+	if !strings.Contains(lines[0], want) {
+		t.Errorf("%s: first line %q, want it to contain %q", code, lines[0], want)
+	}
+	for _, line := range lines {
+		// Syntax errors all end with a // remark; runtime errors never do.
+		if !strings.HasPrefix(line, "Error at line") || !strings.Contains(line, " // ") {
+			t.Errorf("%s: unexpected output line %q", code, line)
+		}
+	}
+}
+
+func TestBoolPrinting(t *testing.T) {
+	tests := []struct {
+		code     string
+		expected string
+	}{
+		{"suna yas;", "yas\n"},
+		{"suna nah;", "nah\n"},
+		{"sun flag = yas; suna flag;", "yas\n"},
+		{"suna 3 > 2;", "yas\n"},
+		{"suna 3 == 2;", "nah\n"},
+		{"sun flag = 1 <= 1; suna flag;", "yas\n"},
+		{"suna yas == nah;", "nah\n"},
+		{"suna yas != nah;", "yas\n"},
+		{"suna yas == 1;", "Error at line 1, col 11: Invalid operation == between yas and 1\n"},
+	}
+	for _, tt := range tests {
+		output := runNPP(t, tt.code)
+		if output != tt.expected {
+			t.Errorf("%s\nGot:\n%q\nWant:%q", tt.code, output, tt.expected)
+		}
+	}
+}
+
+func TestComparisonStoredInVariable(t *testing.T) {
+	tests := []struct {
+		code  string
+		value bool
+		want  string
+	}{
+		{`sun x = 9; sun flag = x > 5; suna flag, type(flag); agar flag { suna "big"; } magar { suna "small"; }`, true, "yas bool\nbig\n"},
+		{`sun x = 2; sun flag = x > 5; suna flag, type(flag); agar flag { suna "big"; } magar { suna "small"; }`, false, "nah bool\nsmall\n"},
+	}
+	for _, tt := range tests {
+		i := core.New()
+		i.CaptureOutput()
+		i.Interpret(parser.New(lexer.New(tt.code), false).ParseProgram())
+		if got := i.Output(); got != tt.want {
+			t.Errorf("%s: got %q, want %q", tt.code, got, tt.want)
+		}
+		flag, _ := i.Lookup("flag")
+		if b, ok := flag.(*core.BoolObject); !ok || b.Value != tt.value {
+			t.Errorf("%s: flag is %#v, want a BoolObject holding %v", tt.code, flag, tt.value)
+		}
+	}
+}
+
+func TestIfConditionTruthiness(t *testing.T) {
+	tests := []struct {
+		code     string
+		expected string
+	}{
+		{`agar 5 > 3 { suna "cmp"; } magar { suna "no"; }`, "cmp\n"},
+		{`agar 5 < 3 { suna "cmp"; } magar { suna "no"; }`, "no\n"},
+		{`agar 1 { suna "int"; } magar { suna "no"; }`, "int\n"},
+		{`agar 0 { suna "int"; } magar { suna "no"; }`, "no\n"},
+		{`sun x = 7; agar x { suna "var"; }`, "var\n"},
+		{`sun ok = 2 == 2; agar ok { suna "flag"; }`, "flag\n"},
+		{`agar nah { suna "yes"; } magar { suna "nope"; }`, "nope\n"},
+		{`agar "" { suna "yes"; } magar { suna "empty"; }`, "empty\n"},
+		{`agar "x" { suna "str"; }`, "str\n"},
+	}
+	for _, tt := range tests {
+		output := runNPP(t, tt.code)
+		if output != tt.expected {
+			t.Errorf("%s\nGot:\n%q\nWant:%q", tt.code, output, tt.expected)
+		}
+	}
+}
+
+func TestHasKey(t *testing.T) {
+	tests := []struct {
+		code     string
+		expected string
+	}{
+		{`sun h = {"a": 1, 2: "two"}; suna has_key(h, "a");`, "yas\n"},
+		{`sun h = {"a": 1, 2: "two"}; suna has_key(h, 2);`, "yas\n"},
+		{`sun h = {"a": 1, 2: "two"}; suna has_key(h, "b");`, "nah\n"},
+		{`sun h = {"a": 1}; agar has_key(h, "z") { suna h["z"]; } magar { suna "missing"; }`, "missing\n"},
+		{`sun h = {"a": 1}; suna h["a"];`, "1\n"},
+		{`suna {"a": 1, "b": yas};`, "{\"a\": 1, \"b\": yas}\n"},
+		{`suna has_key(1, "a");`, "Error at line 1, col 14: has_key expects a hash, got 1\n"},
+	}
+	for _, tt := range tests {
+		output := runNPP(t, tt.code)
+		if output != tt.expected {
+			t.Errorf("%s\nGot:\n%q\nWant:%q", tt.code, output, tt.expected)
+		}
+	}
+}
+
+func TestEntries(t *testing.T) {
+	tests := []struct {
+		code     string
+		expected string
+	}{
+		{`sun h = {"b": 2, "a": 1, "c": 3}; suna entries(h);`, "[[\"b\", 2], [\"a\", 1], [\"c\", 3]]\n"},
+		{`sun e = entries({"x": 10, "y": 20}); suna e[0][0]; suna e[0][1]; suna e[1][0]; suna e[1][1];`, "x\n10\ny\n20\n"},
+		{`suna entries({});`, "[]\n"},
+		{`suna [1, "two", yas];`, "[1, \"two\", yas]\n"},
+		{`suna entries([1]);`, "Error at line 1, col 14: entries expects a hash, got [1]\n"},
+	}
+	for _, tt := range tests {
+		output := runNPP(t, tt.code)
+		if output != tt.expected {
+			t.Errorf("%s\nGot:\n%q\nWant:%q", tt.code, output, tt.expected)
+		}
+	}
+}
+
+func TestNegativeZeroFormatting(t *testing.T) {
+	code := `suna -0;
+suna 0 * -1;
+suna -5 + 5;
+suna -0 - 0;
+sun z = -3 * 0;
+suna z;
+suna -12;`
+	expected := "0\n0\n0\n0\n0\n-12\n"
+	output := runNPP(t, code)
+	if output != expected {
+		t.Errorf("Unexpected output.\nGot:\n%q\nWant:%q", output, expected)
+	}
+}
+
+func TestFloatFormatting(t *testing.T) {
+	tests := []struct {
+		code     string
+		expected string
+	}{
+		{"suna 3.0;", "3.0\n"},
+		{"suna 3.14;", "3.14\n"},
+		{"suna 0.1 + 0.2;", "0.30000000000000004\n"},
+		{"suna 1.5 * 2.0;", "3.0\n"},
+		{"suna 10.0 / 4.0;", "2.5\n"},
+		{"suna -2.5;", "-2.5\n"},
+		{"suna -0.0;", "0.0\n"},
+		{"suna 0.0 * -1.0;", "0.0\n"},
+		{"suna 1000000.0 * 1000000.0 * 1000000.0 * 1000.0;", "1e+21\n"},
+		{"suna 2.5 > 2.0;", "yas\n"},
+		{"sun pi = 3.14; suna pi * 2, type(pi);", "6.28 float\n"},
+	}
+	for _, tt := range tests {
+		output := runNPP(t, tt.code)
+		if output != tt.expected {
+			t.Errorf("%s\nGot:\n%q\nWant:%q", tt.code, output, tt.expected)
+		}
+	}
+}
+
+func TestFhenkHalts(t *testing.T) {
+	tests := []struct {
+		code     string
+		expected string
+	}{
+		{`suna 1; fhenk("boom"); suna 2;`, "1\nError at line 1, col 15: boom\n"},
+		{`agar yas {
+    fhenk("inside");
+    suna "unreachable";
+}
+suna "also unreachable";`, "Error at line 2, col 11: inside\n"},
+		{`sun x = fhenk("in expr"); suna x;`, "Error at line 1, col 15: in expr\n"},
+		// Rethrowing a caught error keeps its message and where it was first raised.
+		{`koshish { fhenk("boom"); } pakad (e) { suna "cleanup"; fhenk(e); }`, "cleanup\nError at line 1, col 17: boom\n"},
+		{`koshish { koshish { sun x = 1 / 0; } pakad (e) { fhenk(e); } } pakad (outer) { suna error_line(outer), outer; }`,
+			"1 Error at line 1, col 32: Division by zero\n"},
+	}
+	for _, tt := range tests {
+		output := runNPP(t, tt.code)
+		if output != tt.expected {
+			t.Errorf("%s\nGot:\n%q\nWant:%q", tt.code, output, tt.expected)
+		}
+	}
+}
+
+func TestTryCatch(t *testing.T) {
+	tests := []struct {
+		code     string
+		expected string
+	}{
+		{`koshish {
+    suna 1 / 0;
+    suna "unreachable";
+} pakad (e) {
+    suna e;
+}
+suna "after";`, "Error at line 2, col 13: Division by zero\nafter\n"},
+		{`koshish { fhenk("boom"); } pakad (err) { suna "caught"; suna err; }`, "caught\nError at line 1, col 17: boom\n"},
+		{`koshish { suna "fine"; } pakad (e) { suna "never"; }`, "fine\n"},
+		{`sun e = "outer"; koshish { fhenk("x"); } pakad (e) { sun copy = e; } suna e;`, "outer\n"},
+		{`koshish { fhenk("first"); } pakad (e) { fhenk("second"); } suna "unreachable";`, "Error at line 1, col 47: second\n"},
+		{`koshish { koshish { fhenk("inner"); } pakad (e) { fhenk("rethrown"); } } pakad (e) { suna e; }`, "Error at line 1, col 57: rethrown\n"},
+	}
+	for _, tt := range tests {
+		output := runNPP(t, tt.code)
+		if output != tt.expected {
+			t.Errorf("%s\nGot:\n%q\nWant:%q", tt.code, output, tt.expected)
+		}
+	}
+}
+
+func TestTryFinally(t *testing.T) {
+	tests := []struct {
+		code     string
+		expected string
+	}{
+		{`koshish { suna "try"; } pakad (e) { suna "catch"; } aakhir { suna "finally"; }`, "try\nfinally\n"},
+		{`koshish { fhenk("boom"); } pakad (e) { suna "catch"; } aakhir { suna "finally"; }`, "catch\nfinally\n"},
+		{`koshish { fhenk("boom"); } aakhir { suna "finally"; } suna "unreachable";`, "finally\nError at line 1, col 17: boom\n"},
+		{`koshish { fhenk("a"); } pakad (e) { fhenk("b"); } aakhir { suna "finally"; }`, "finally\nError at line 1, col 43: b\n"},
+		{`koshish { suna "try"; } aakhir { suna "finally"; } suna "after";`, "try\nfinally\nafter\n"},
+	}
+	for _, tt := range tests {
+		output := runNPP(t, tt.code)
+		if output != tt.expected {
+			t.Errorf("%s\nGot:\n%q\nWant:%q", tt.code, output, tt.expected)
+		}
+	}
+}
+
+func TestRunMultipleFiles(t *testing.T) {
+	dir := t.TempDir()
+	first := filepath.Join(dir, "first.npp")
+	second := filepath.Join(dir, "second.npp")
+	if err := os.WriteFile(first, []byte(`sun greeting = "hello from first";`), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(second, []byte(`suna greeting; sun greeting = "changed"; suna greeting;`), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	output := captureStdout(t, func() { run([]string{first, second}) })
+	expected := "hello from first\nchanged\n"
+	if output != expected {
+		t.Errorf("Unexpected output.\nGot:\n%q\nWant:%q", output, expected)
+	}
+
+	output = captureStdout(t, func() { run([]string{first, filepath.Join(dir, "notes.txt")}) })
+	expected = "Invalid file type. Please provide a .npp file.\n"
+	if output != expected {
+		t.Errorf("Unexpected output.\nGot:\n%q\nWant:%q", output, expected)
+	}
+}
+
+// fakeWatcher reports a change for each queued edit, applying it first, then stops.
+type fakeWatcher struct {
+	edits []func()
+}
+
+func (w *fakeWatcher) Wait() error {
+	if len(w.edits) == 0 {
+		return errors.New("no more changes")
+	}
+	w.edits[0]()
+	w.edits = w.edits[1:]
+	return nil
+}
+
+func TestWatchRerunsOnChange(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "watched.npp")
+	write := func(code string) {
+		if err := os.WriteFile(path, []byte(code), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	write(`suna "v1";`)
+
+	w := &fakeWatcher{edits: []func(){func() { write(`suna "v2";`) }}}
+	output := captureStdout(t, func() { watch([]string{path}, w) })
+	expected := "v1\n----- file changed, re-running -----\nv2\nStopped watching: no more changes\n"
+	if output != expected {
+		t.Errorf("Unexpected output.\nGot:\n%q\nWant:%q", output, expected)
+	}
+}
+
+func TestPollWatcherDetectsModTime(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "watched.npp")
+	if err := os.WriteFile(path, []byte(`suna 1;`), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	w, err := newPollWatcher([]string{path}, time.Millisecond)
+	if err != nil {
+		t.Fatal(err)
+	}
+	later := time.Now().Add(time.Hour)
+	if err := os.Chtimes(path, later, later); err != nil {
+		t.Fatal(err)
+	}
+	done := make(chan error, 1)
+	go func() { done <- w.Wait() }()
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Errorf("Wait returned error: %v", err)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("Wait did not notice the modified file")
+	}
+}
+
+func TestDepthAtTopLevel(t *testing.T) {
+	tests := []struct {
+		code     string
+		expected string
+	}{
+		{"suna depth();", "0\n"},
+		{"agar yas { suna depth(); }", "0\n"},
+		{"suna depth(1);", "Error at line 1, col 12: depth expects 0 argument(s), got 1\n"},
+	}
+	for _, tt := range tests {
+		output := runNPP(t, tt.code)
+		if output != tt.expected {
+			t.Errorf("%s\nGot:\n%q\nWant:%q", tt.code, output, tt.expected)
+		}
+	}
+}
+
+func TestChrOrd(t *testing.T) {
+	tests := []struct {
+		code     string
+		expected string
+	}{
+		{"suna chr(65);", "A\n"},
+		{`suna ord("A");`, "65\n"},
+		{`suna ord(chr(955));`, "955\n"},
+		{`suna chr(ord("z"));`, "z\n"},
+		{"suna chr(-1);", "Error at line 1, col 10: chr: -1 is not a valid code point\n"},
+		{"suna chr(1114112);", "Error at line 1, col 10: chr: 1114112 is not a valid code point\n"},
+		{`suna chr("A");`, "Error at line 1, col 10: chr expects an int, got A\n"},
+		{`suna ord("AB");`, "Error at line 1, col 10: ord expects a single character, got \"AB\"\n"},
+		{`suna ord("");`, "Error at line 1, col 10: ord expects a single character, got \"\"\n"},
+		{`suna ord(65);`, "Error at line 1, col 10: ord expects a string, got 65\n"},
+	}
+	for _, tt := range tests {
+		output := runNPP(t, tt.code)
+		if output != tt.expected {
+			t.Errorf("%s\nGot:\n%q\nWant:%q", tt.code, output, tt.expected)
+		}
+	}
+}
+
+func TestSubstr(t *testing.T) {
+	tests := []struct {
+		code     string
+		expected string
+	}{
+		{`suna substr("hello", 1, 3);`, "ell\n"},
+		{`suna substr("hello", 0, 5);`, "hello\n"},
+		{`suna substr("hello", 3, 100);`, "lo\n"},
+		{`suna substr("hello", -2, 2);`, "he\n"},
+		{`suna substr("hello", 10, 2);`, "\n"},
+		{`suna substr("hello", 2, 0);`, "\n"},
+		{`suna substr("héllo", 1, 2);`, "él\n"},
+		{`suna substr("hello", 1, -1);`, "Error at line 1, col 13: substr: negative length -1\n"},
+		{`suna substr(5, 1, 1);`, "Error at line 1, col 13: substr expects a string, got 5\n"},
+	}
+	for _, tt := range tests {
+		output := runNPP(t, tt.code)
+		if output != tt.expected {
+			t.Errorf("%s\nGot:\n%q\nWant:%q", tt.code, output, tt.expected)
+		}
+	}
+}
+
+func TestReplace(t *testing.T) {
+	tests := []struct {
+		code     string
+		expected string
+	}{
+		{`suna replace("a-b-c", "-", "+");`, "a+b+c\n"},
+		{`suna replace("banana", "an", "AN");`, "bANANa\n"},
+		{`suna replace("hello", "xyz", "!");`, "hello\n"},
+		{`suna replace("hello", "l", "");`, "heo\n"},
+		{`suna replace("abc", "b", 1);`, "Error at line 1, col 14: replace expects strings, got 1\n"},
+	}
+	for _, tt := range tests {
+		output := runNPP(t, tt.code)
+		if output != tt.expected {
+			t.Errorf("%s\nGot:\n%q\nWant:%q", tt.code, output, tt.expected)
+		}
+	}
+}
+
+func TestBoolArithmeticErrors(t *testing.T) {
+	tests := []struct {
+		code     string
+		expected string
+	}{
+		{"suna yas + 1;", "Error at line 1, col 11: Cannot use boolean yas as the left operand of +\n"},
+		{"suna 1 - nah;", "Error at line 1, col 9: Cannot use boolean nah as the right operand of -\n"},
+		{"sun b = 2 > 1; suna b * 3;", "Error at line 1, col 24: Cannot use boolean yas as the left operand of *\n"},
+		{`suna "x" + yas;`, "Error at line 1, col 11: Cannot use boolean yas as the right operand of +\n"},
+	}
+	for _, tt := range tests {
+		output := runNPP(t, tt.code)
+		if output != tt.expected {
+			t.Errorf("%s\nGot:\n%q\nWant:%q", tt.code, output, tt.expected)
+		}
+	}
+}
+
+func TestCheck(t *testing.T) {
+	dir := t.TempDir()
+	valid := filepath.Join(dir, "valid.npp")
+	invalid := filepath.Join(dir, "invalid.npp")
+	if err := os.WriteFile(valid, []byte(`sun x = 1; suna x / 0;`), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(invalid, []byte(`sun = 1;`), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	var status int
+	output := captureStdout(t, func() { status = check([]string{valid}) })
+	if status != 0 || output != "" {
+		t.Errorf("check(valid) = %d, output %q; want 0 and no output", status, output)
+	}
+
+	output = captureStdout(t, func() { status = check([]string{valid, invalid}) })
+	if status != 1 {
+		t.Errorf("check(invalid) = %d, want 1", status)
+	}
+	if !strings.Contains(output, "Expected identifier after SUN") || !strings.Contains(output, invalid+": ") {
+		t.Errorf("Unexpected output: %q", output)
+	}
+}
+
+func TestParserErrors(t *testing.T) {
+	var errs []parser.ParseError
+	captureStdout(t, func() {
+		p := parser.New(lexer.New("suna 1;\nsun 5 = 2;"), false)
+		p.ParseProgram()
+		errs = p.Errors()
+	})
+	if len(errs) == 0 {
+		t.Fatal("expected syntax errors")
+	}
+	if errs[0].Line != 2 || !strings.HasPrefix(errs[0].Message, "Expected identifier after SUN, got INT") {
+		t.Errorf("Unexpected first error: %+v", errs[0])
+	}
+}
+
+func TestREPLTypes(t *testing.T) {
+	input := strings.Join([]string{
+		`sun x = 41`,
+		`x + 1`,
+		`:types`,
+		`x + 1`,
+		`"hi"`,
+		`[1, 2.5]`,
+		`x > 1`,
+		`type(x)`,
+		`suna "printed"`,
+		`1 / 0`,
+		`:types`,
+		`x`,
+	}, "\n")
+	output := captureStdout(t, func() { repl(strings.NewReader(input)) })
+	expected := ">> >> => 42\n" +
+		">> types on\n" +
+		">> => 42 : int\n" +
+		">> => hi : string\n" +
+		">> => [1, 2.5] : array\n" +
+		">> => yas : bool\n" +
+		">> => int : string\n" +
+		">> printed\n" +
+		">> Error at line 1, col 4: Division by zero\n" +
+		">> types off\n" +
+		">> => 41\n" +
+		">> \n"
+	if output != expected {
+		t.Errorf("Unexpected output.\nGot:\n%q\nWant:%q", output, expected)
+	}
+}
+
+func TestCaptureOutput(t *testing.T) {
+	code := `sun a = "hello "; suna a + "world"; suna 1 + 2; suna 1 / 0; suna "unreachable";`
+	i := core.New()
+	i.CaptureOutput()
+	stdout := captureStdout(t, func() {
+		i.Interpret(parser.New(lexer.New(code), false).ParseProgram())
+	})
+	expected := "hello world\n3\nError at line 1, col 57: Division by zero\n"
+	if i.Output() != expected {
+		t.Errorf("Unexpected captured output.\nGot:\n%q\nWant:%q", i.Output(), expected)
+	}
+	if stdout != "" {
+		t.Errorf("Captured output leaked to stdout: %q", stdout)
+	}
+
+	var buf strings.Builder
+	i = core.New()
+	i.Out = &buf
+	i.Interpret(parser.New(lexer.New(`suna "to writer";`), false).ParseProgram())
+	if buf.String() != "to writer\n" || i.Output() != "" {
+		t.Errorf("Unexpected writer output %q, captured %q", buf.String(), i.Output())
+	}
+}
+
+func TestModuloPrecedence(t *testing.T) {
+	tests := []struct {
+		code     string
+		ast      string
+		expected string
+	}{
+		// A variable operand keeps constant folding from hiding the shape.
+		{"sun x = 10; suna x % 3 * 2;", "sun x = 10\nsuna ((x % 3) * 2)\n", "2\n"},
+		{"sun x = 2; suna x * 10 % 3;", "sun x = 2\nsuna ((x * 10) % 3)\n", "2\n"},
+		{"sun x = 17; suna x % 5 % 3;", "sun x = 17\nsuna ((x % 5) % 3)\n", "2\n"},
+		{"sun x = 1; suna x + 7 % 4;", "sun x = 1\nsuna (x + 3)\n", "4\n"},
+		{"sun x = 0; suna 10 % x;", "sun x = 0\nsuna (10 % x)\n", "Error at line 1, col 21: Modulo by zero\n"},
+	}
+	for _, tt := range tests {
+		var program *parser.Program
+		captureStdout(t, func() { program = parser.New(lexer.New(tt.code), false).ParseProgram() })
+		if program.String() != tt.ast {
+			t.Errorf("%s\nGot AST:\n%q\nWant:%q", tt.code, program.String(), tt.ast)
+		}
+		output := runNPP(t, tt.code)
+		if output != tt.expected {
+			t.Errorf("%s\nGot:\n%q\nWant:%q", tt.code, output, tt.expected)
+		}
+	}
+}
+
+func TestLenInConditions(t *testing.T) {
+	tests := []struct {
+		code     string
+		expected string
+	}{
+		{`sun arr = [1, 2, 3]; agar len(arr) > 0 { suna "not empty"; } magar { suna "empty"; }`, "not empty\n"},
+		{`sun arr = []; agar len(arr) > 0 { suna "not empty"; } magar { suna "empty"; }`, "empty\n"},
+		{`sun arr = []; agar len(arr) { suna "not empty"; } magar { suna "empty"; }`, "empty\n"},
+		{`suna len({"a": 1, "b": 2});`, "2\n"},
+		{`suna len("héllo");`, "5\n"},
+		{`suna len(5);`, "Error at line 1, col 10: len not supported for int\n"},
+	}
+	for _, tt := range tests {
+		output := runNPP(t, tt.code)
+		if output != tt.expected {
+			t.Errorf("%s\nGot:\n%q\nWant:%q", tt.code, output, tt.expected)
+		}
+	}
+}
+
+func TestLenEqualsZeroInConditions(t *testing.T) {
+	tests := []struct {
+		code     string
+		expected string
+	}{
+		{`sun s = ""; agar len(s) == 0 { suna "empty"; } magar { suna "full"; }`, "empty\n"},
+		{`sun s = "hi"; agar len(s) == 0 { suna "empty"; } magar { suna "full"; }`, "full\n"},
+		{`sun a = [1]; agar len(a) != 0 && len(a) == 1 { suna "one"; }`, "one\n"},
+		{`agar len({}) == 0 { suna "no keys"; }`, "no keys\n"},
+		{`sun s = "abc"; grind len(s) != 0 { sun s = substr(s, 1, 10); suna s; }`, "bc\nc\n\n"},
+		{`sun empty = len("") == 0; suna empty, type(empty);`, "yas bool\n"},
+	}
+	for _, tt := range tests {
+		output := runNPP(t, tt.code)
+		if output != tt.expected {
+			t.Errorf("%s\nGot:\n%q\nWant:%q", tt.code, output, tt.expected)
+		}
+	}
+}
+
+func TestReverse(t *testing.T) {
+	tests := []struct {
+		code     string
+		expected string
+	}{
+		{`suna reverse("abc");`, "cba\n"},
+		{`suna reverse([1, 2, 3]);`, "[3, 2, 1]\n"},
+		{`suna reverse("héllo");`, "olléh\n"},
+		{`suna reverse([]);`, "[]\n"},
+		{`sun a = [1, 2]; sun b = reverse(a); suna a; suna b;`, "[1, 2]\n[2, 1]\n"},
+		{`suna reverse(12);`, "Error at line 1, col 14: reverse not supported for int\n"},
+	}
+	for _, tt := range tests {
+		output := runNPP(t, tt.code)
+		if output != tt.expected {
+			t.Errorf("%s\nGot:\n%q\nWant:%q", tt.code, output, tt.expected)
+		}
+	}
+}
+
+func TestVarsDeclarationOrder(t *testing.T) {
+	i := core.New()
+	i.CaptureOutput()
+	i.Interpret(parser.New(lexer.New(`sun zeta = 1; sun alpha = 2; sun mid = 3; sun alpha = 4; sun beta = 5;`), false).ParseProgram())
+	got := strings.Join(i.Vars(), ",")
+	if got != "zeta,alpha,mid,beta" {
+		t.Errorf("Vars() = %s, want zeta,alpha,mid,beta", got)
+	}
+
+	output := captureStdout(t, func() { repl(strings.NewReader("sun b = 1\nsun a = \"x\"\n:vars\n")) })
+	expected := ">> >> >> b = 1\na = x\n>> \n"
+	if output != expected {
+		t.Errorf("Unexpected output.\nGot:\n%q\nWant:%q", output, expected)
+	}
+}
+
+func TestTwoCharOperatorColumns(t *testing.T) {
+	columnOf := func(input string, tokenType lexer.TokenType) int {
+		l := lexer.New(input)
+		for tok := l.NextToken(); tok.Type != lexer.EOF; tok = l.NextToken() {
+			if tok.Type == tokenType {
+				return tok.Column
+			}
+		}
+		t.Fatalf("no %s token in %q", tokenType, input)
+		return 0
+	}
+	base := columnOf("x < 10", lexer.LT)
+	for _, tt := range []struct {
+		input     string
+		tokenType lexer.TokenType
+	}{
+		{"x <= 10", lexer.LE},
+		{"x >= 10", lexer.GE},
+		{"x == 10", lexer.EQ},
+		{"x != 10", lexer.NOT_EQ},
+	} {
+		if got := columnOf(tt.input, tt.tokenType); got != base {
+			t.Errorf("%q: %s column = %d, want %d (first character)", tt.input, tt.tokenType, got, base)
+		}
+	}
+
+	output := runNPP(t, `suna yas <= 1;`)
+	expected := "Error at line 1, col 11: Cannot order bool and int with <=\n"
+	if output != expected {
+		t.Errorf("Unexpected output.\nGot:\n%q\nWant:%q", output, expected)
+	}
+}
+
+func TestKeywordSuggestions(t *testing.T) {
+	tests := []struct {
+		code     string
+		expected string
+	}{
+		{`agr 5 > 3 { suna 1; }`, "Unknown identifier 'agr', did you mean 'agar'? // Learn to spell, genius!"},
+		{`sunaa "hi";`, "Unknown identifier 'sunaa', did you mean 'suna'? // Learn to spell, genius!"},
+		{`magr x = 2;`, "Unknown identifier 'magr', did you mean 'magar'? // Learn to spell, genius!"},
+		{`xylophone "hi";`, "Unknown identifier 'xylophone' at start of statement // Keep it together, genius!"},
+	}
+	for _, tt := range tests {
+		var errs []parser.ParseError
+		captureStdout(t, func() {
+			p := parser.New(lexer.New(tt.code), false)
+			p.ParseProgram()
+			errs = p.Errors()
+		})
+		if len(errs) == 0 || errs[0].Message != tt.expected {
+			t.Errorf("%s\nGot errors: %+v\nWant first: %q", tt.code, errs, tt.expected)
+		}
+	}
+
+	output := runNPP(t, `sun agr = 1; agr; suna agr + 1;`)
+	if output != "2\n" {
+		t.Errorf("Identifier close to a keyword should still be usable, got %q", output)
+	}
+}
+
+func TestByteLenVersusLen(t *testing.T) {
+	tests := []struct {
+		code     string
+		expected string
+	}{
+		{`suna len("abc"); suna byte_len("abc");`, "3\n3\n"},
+		{`suna len("héllo"); suna byte_len("héllo");`, "5\n6\n"},
+		{`suna len("日本"); suna byte_len("日本");`, "2\n6\n"},
+		{`suna byte_len("");`, "0\n"},
+		{`suna byte_len([1]);`, "Error at line 1, col 15: byte_len expects a string, got array\n"},
+	}
+	for _, tt := range tests {
+		output := runNPP(t, tt.code)
+		if output != tt.expected {
+			t.Errorf("%s\nGot:\n%q\nWant:%q", tt.code, output, tt.expected)
+		}
+	}
+}
+
+func TestPrintFlushesBufferedWriter(t *testing.T) {
+	var sink strings.Builder
+	buffered := bufio.NewWriterSize(&sink, 4096)
+	i := core.New()
+	i.Out = buffered
+	i.Interpret(parser.New(lexer.New(`suna "first";`), false).ParseProgram())
+	if sink.String() != "first\n" {
+		t.Errorf("Output was not flushed after suna, sink has %q", sink.String())
+	}
+	i.Interpret(parser.New(lexer.New(`suna 1 / 0;`), false).ParseProgram())
+	if !strings.HasSuffix(sink.String(), "Division by zero\n") {
+		t.Errorf("Error was not flushed, sink has %q", sink.String())
+	}
+}
+
+func TestNegativeDivisionAndModulo(t *testing.T) {
+	tests := []struct {
+		code     string
+		expected string
+	}{
+		{"suna -7 / 2;", "-3\n"},
+		{"suna -7 % 2;", "-1\n"},
+		{"suna 7 / -2;", "-3\n"},
+		{"suna 7 % -2;", "1\n"},
+		{"suna -7 / -2;", "3\n"},
+		{"suna -7 % -2;", "-1\n"},
+		{"suna 7 / 2;", "3\n"},
+		{"suna 7 % 2;", "1\n"},
+		{"suna -6 / 3;", "-2\n"},
+		{"suna -6 % 3;", "0\n"},
+		{"suna -1 / 2;", "0\n"},
+		{"sun q = -7 / 2; sun r = -7 % 2; suna q * 2 + r;", "-7\n"},
+	}
+	for _, tt := range tests {
+		output := runNPP(t, tt.code)
+		if output != tt.expected {
+			t.Errorf("%s\nGot:\n%q\nWant:%q", tt.code, output, tt.expected)
+		}
+	}
+}
+
+func TestBooleanSingletons(t *testing.T) {
+	i := core.New()
+	i.CaptureOutput()
+	for _, tt := range []struct {
+		code     string
+		expected *core.BoolObject
+	}{
+		{"1 < 2", core.TRUE},
+		{"1 > 2", core.FALSE},
+		{"yas", core.TRUE},
+		{"nah", core.FALSE},
+		{"2.5 >= 2.5", core.TRUE},
+		{`has_key({"a": 1}, "a")`, core.TRUE},
+	} {
+		program := parser.New(lexer.New(tt.code), false).ParseProgram()
+		result := i.Eval(program.Statements[0])
+		if result != core.Object(tt.expected) {
+			t.Errorf("%s: got %p (%v), want shared singleton %p", tt.code, result, result, tt.expected)
+		}
+	}
+	if core.TRUE.Value != true || core.FALSE.Value != false || core.NULL.String() != "khali" {
+		t.Error("singletons were modified")
+	}
+}
+
+func TestSmallIntsAreShared(t *testing.T) {
+	i := core.New()
+	eval := func(code string) core.Object {
+		return i.Eval(parser.New(lexer.New(code), false).ParseProgram().Statements[0])
+	}
+	if eval("2 + 3") != eval("10 - 5") {
+		t.Error("small ints should reuse the cached object")
+	}
+	if eval("1000 + 1") == eval("1002 - 1") {
+		t.Error("large ints should not be cached")
+	}
+	if got := eval("200 + 55").String(); got != "255" {
+		t.Errorf("200 + 55 = %s, want 255", got)
+	}
+}
+
+func TestNewReaderMatchesNew(t *testing.T) {
+	input := "sun x = 3.5 * 2;\nagar x >= 7 { suna \"big\"; } magar { suna [1, {\"k\": nah}]; }\n"
+	fromString := lexer.New(input)
+	fromReader, err := lexer.NewReader(iotest.OneByteReader(strings.NewReader(input)))
+	if err != nil {
+		t.Fatalf("NewReader failed: %v", err)
+	}
+	for {
+		want, got := fromString.NextToken(), fromReader.NextToken()
+		if got != want {
+			t.Fatalf("token mismatch: got %+v, want %+v", got, want)
+		}
+		if want.Type == lexer.EOF {
+			break
+		}
+	}
+
+	if _, err := lexer.NewReader(iotest.ErrReader(errors.New("disk on fire"))); err == nil {
+		t.Error("expected NewReader to report read errors")
+	}
+}
+
+func TestPositionOffsetRoundTrip(t *testing.T) {
+	input := "sun x = 1;\n\nsuna x;\nsuna \"done\";"
+	tests := []struct {
+		line, column, offset int
+	}{
+		{1, 1, 0},
+		{1, 5, 4},
+		{1, 11, 10}, // the newline ending line 1
+		{2, 1, 11},  // an empty line
+		{3, 6, 17},
+		{4, 13, 32}, // end of input
+	}
+	for _, tt := range tests {
+		offset, ok := lexer.Offset(input, tt.line, tt.column)
+		if !ok || offset != tt.offset {
+			t.Errorf("Offset(%d, %d) = %d, %v; want %d", tt.line, tt.column, offset, ok, tt.offset)
+		}
+		if line, column := lexer.Position(input, tt.offset); line != tt.line || column != tt.column {
+			t.Errorf("Position(%d) = %d:%d, want %d:%d", tt.offset, line, column, tt.line, tt.column)
+		}
+	}
+
+	for _, pos := range [][2]int{{0, 1}, {1, 0}, {1, 12}, {2, 2}, {5, 1}} {
+		if offset, ok := lexer.Offset(input, pos[0], pos[1]); ok {
+			t.Errorf("Offset(%d, %d) = %d, want out of range", pos[0], pos[1], offset)
+		}
+	}
+}
+
+func TestLeadingCommentAttachesToNextToken(t *testing.T) {
+	input := `sun x = 1; // trailing, not documentation
+
+// greet says hello.
+//   Indentation after the first space is kept.
+glow greet() {
+    suna "hi";
+}
+
+// orphaned by the blank line below
+
+sun y = 2;`
+	l := lexer.New(input)
+	l.AttachComments = true
+	comments := map[string]string{}
+	for tok := l.NextToken(); tok.Type != lexer.EOF; tok = l.NextToken() {
+		if tok.LeadingComment != "" {
+			comments[tok.Literal] = tok.LeadingComment
+		}
+	}
+	want := map[string]string{"glow": "greet says hello.\n  Indentation after the first space is kept."}
+	if len(comments) != len(want) || comments["glow"] != want["glow"] {
+		t.Errorf("leading comments = %q, want %q", comments, want)
+	}
+
+	plain := lexer.New(input)
+	for tok := plain.NextToken(); tok.Type != lexer.EOF; tok = plain.NextToken() {
+		if tok.LeadingComment != "" {
+			t.Fatalf("comments should be discarded by default, got %q on %q", tok.LeadingComment, tok.Literal)
+		}
+	}
+}
+
+func TestCommentsBetweenStatements(t *testing.T) {
+	code := "// header\nsun x = 1; // set x\n  // indented\nsuna x / 1; // division still works\n// trailer"
+	if got := runNPP(t, code); got != "1\n" {
+		t.Errorf("got %q, want %q", got, "1\n")
+	}
+}
+
+func TestPretty(t *testing.T) {
+	code := `sun data = {"name": "npp", "tags": ["fast", "sassy"], "meta": {"stars": 5, "empty": []}};
+suna pretty(data);
+suna pretty([]);
+suna pretty(42);`
+	want := `{
+  "name": "npp",
+  "tags": [
+    "fast",
+    "sassy"
+  ],
+  "meta": {
+    "stars": 5,
+    "empty": []
+  }
+}
+[]
+42
+`
+	if got := runNPP(t, code); got != want {
+		t.Errorf("got:\n%s\nwant:\n%s", got, want)
+	}
+}
+
+func TestPrintMultipleValues(t *testing.T) {
+	tests := []struct {
+		code string
+		want string
+	}{
+		{`suna 1, "two", 3.0;`, "1 two 3.0\n"},
+		{`suna(1, "two", 3.0);`, "1 two 3.0\n"},
+		{`sun x = 4; suna "x is", x * 2;`, "x is 8\n"},
+		{`sun x = 4; suna("x is", x * 2);`, "x is 8\n"},
+		{`suna("solo");`, "solo\n"},
+		{`sun a = [5, 6]; suna (a)[1] + 1;`, "7\n"},
+		{`sun a = 2; suna (a) * 3, a;`, "6 2\n"},
+		{`suna 1, 2 / 0;`, "Error at line 1, col 12: Division by zero\n"},
+	}
+	for _, tt := range tests {
+		if got := runNPP(t, tt.code); got != tt.want {
+			t.Errorf("%s: got %q, want %q", tt.code, got, tt.want)
+		}
+	}
+
+	for _, code := range []string{`suna();`, `suna(1, 2) + 3;`, `suna(1, 2), 3;`, `suna 1,;`} {
+		p := parser.New(lexer.New(code), false)
+		captureStdout(t, func() { p.ParseProgram() })
+		if len(p.Errors()) == 0 {
+			t.Errorf("%s: expected a syntax error", code)
+		}
+	}
+}
+
+func TestOnStatementHook(t *testing.T) {
+	code := `sun x = 3;
+agar x > 1 {
+    suna "big";
+    suna x;
+} magar {
+    suna "small";
+}
+koshish {
+    suna x / 0;
+} pakad (e) {
+    suna "caught";
+}`
+	program := parser.New(lexer.New(code), false).ParseProgram()
+	i := core.New()
+	i.CaptureOutput()
+	var lines []int
+	i.OnStatement = func(stmt parser.Statement) {
+		lines = append(lines, stmt.Token().Line)
+	}
+	i.Interpret(program)
+
+	// sun, agar, two prints, koshish, the failing print and the handler print.
+	want := []int{1, 2, 3, 4, 8, 9, 11}
+	if !slices.Equal(lines, want) {
+		t.Errorf("OnStatement saw lines %v, want %v", lines, want)
+	}
+}
+
+func TestDebuggerSteps(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "debug.npp")
+	code := "sun x = 1;\nsuna x;\nsun x = x + 1;\nsuna x;\n"
+	if err := os.WriteFile(path, []byte(code), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	commands := "s\n\nbogus\nvars\nc\n"
+	got := captureStdout(t, func() { debug([]string{path}, strings.NewReader(commands)) })
+	want := `line 1: sun x = 1
+(debug) line 2: suna x
+(debug) 1
+line 3: sun x = (x + 1)
+(debug) Unknown command, try step (s), continue (c) or vars (v)
+(debug) x = 1
+(debug) 2
+`
+	if got != want {
+		t.Errorf("got:\n%s\nwant:\n%s", got, want)
+	}
+
+	// Running out of commands lets the program finish.
+	got = captureStdout(t, func() { debug([]string{path}, strings.NewReader("")) })
+	if !strings.HasSuffix(got, "1\n2\n") {
+		t.Errorf("program should finish once input ends, got %q", got)
+	}
+	// A baar loop has a count instead of a condition.
+	path = filepath.Join(t.TempDir(), "baar.npp")
+	if err := os.WriteFile(path, []byte("bahar: baar 2 { suna 1; }\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	got = captureStdout(t, func() { debug([]string{path}, strings.NewReader("c\n")) })
+	if want := "line 1: bahar: baar 2 { ... }\n(debug) 1\n1\n"; got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+	program := parser.New(lexer.New("baar n { suna 1; }\ngrind yas { tod; }"), false).ParseProgram()
+	if got := program.String(); got != "baar n { ... }\ngrind yas { ... }\n" {
+		t.Errorf("got AST %q", got)
+	}
+}
+
+func TestCoverReportsUntakenBranch(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "cover.npp")
+	code := `sun x = 5;
+agar x > 1 {
+    suna "big";
+} magar {
+    suna "small";
+}
+sun y = 1; suna y / 0;
+suna "unreached";
+`
+	if err := os.WriteFile(path, []byte(code), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	got := captureStdout(t, func() { cover([]string{path}) })
+	want := `big
+Error at line 7, col 20: Division by zero
+----- coverage -----
+line 1: covered
+line 2: covered
+line 3: covered
+line 5: NOT covered
+line 7: covered
+line 8: NOT covered
+5/7 statements covered (71.4%)
+`
+	if got != want {
+		t.Errorf("got:\n%s\nwant:\n%s", got, want)
+	}
+}
+
+func TestLogicalShortCircuit(t *testing.T) {
+	tests := []struct {
+		code string
+		want string
+	}{
+		{`suna yas && nah, yas && yas, nah || yas, nah || nah;`, "nah yas yas nah\n"},
+		{`suna 1 < 2 && 3 > 4 || 2 == 2;`, "yas\n"},
+		{`suna 1 && "", 0 || "s";`, "nah yas\n"},
+		{`sun x = 5; agar x > 0 && x < 10 { suna "in range"; }`, "in range\n"},
+		{`suna nah && nah || yas, nah && (nah || yas);`, "yas nah\n"},
+		// fhenk raises an error whenever it is called, so these only pass
+		// if the right operand is skipped.
+		{`agar nah && fhenk("right side of && ran") { suna "then"; } magar { suna "else"; }`, "else\n"},
+		{`agar 1 == 1 || fhenk("right side of || ran") { suna "then"; }`, "then\n"},
+		{`sun x = 0; agar x != 0 && 10 / x > 1 { suna "big"; } magar { suna "guarded"; }`, "guarded\n"},
+		// And it is evaluated when the left operand doesn't decide.
+		{`agar yas && fhenk("right side ran") { suna "then"; }`, "Error at line 1, col 19: right side ran\n"},
+	}
+	for _, tt := range tests {
+		if got := runNPP(t, tt.code); got != tt.want {
+			t.Errorf("%s: got %q, want %q", tt.code, got, tt.want)
+		}
+	}
+}
+
+func TestShebangLineIsIgnored(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "script.npp")
+	code := "#!/usr/bin/env npp\nsun x = 2;\nsuna x * 21;\nsuna x / 0;\n"
+	if err := os.WriteFile(path, []byte(code), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	got := captureStdout(t, func() { run([]string{path}) })
+	if want := "42\nError at line 4, col 9: Division by zero\n"; got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+
+	// Only the very first line can be a shebang.
+	p := parser.New(lexer.New("suna 1;\n#!/usr/bin/env npp\n"), false)
+	captureStdout(t, func() { p.ParseProgram() })
+	if len(p.Errors()) == 0 {
+		t.Error("expected a syntax error for a shebang after the first line")
+	}
+}
+
+func TestTripleQuotedStrings(t *testing.T) {
+	code := "sun poem = \"\"\"roses are red,\n  \"quotes\" are fine\n\"\"\";\nsuna poem;\nsuna len(poem);\nsuna 1 / 0;"
+	want := "roses are red,\n  \"quotes\" are fine\n\n35\nError at line 6, col 9: Division by zero\n"
+	if got := runNPP(t, code); got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+
+	l := lexer.New("suna \"\"\"never\nclosed\"\";")
+	l.NextToken()
+	tok := l.NextToken()
+	if tok.Type != lexer.ILLEGAL || tok.Literal != "never\nclosed\"\";" {
+		t.Errorf("unterminated triple-quoted string: got %s %q, want ILLEGAL", tok.Type, tok.Literal)
+	}
+	if tok = l.NextToken(); tok.Type != lexer.EOF {
+		t.Errorf("expected EOF after an unterminated string, got %s", tok.Type)
+	}
+}
+
+func TestRawStrings(t *testing.T) {
+	tests := []struct {
+		code string
+		want string
+	}{
+		{"suna `C:\\new\\table`;", "C:\\new\\table\n"},
+		{`suna "C:\\new\\table";`, "C:\\new\\table\n"},
+		{`suna "C:\new";`, "C:\new\n"},
+		{"suna len(`\\d+\\t`), len(\"\\d+\\t\");", "5 4\n"},
+		{`suna "say \"hi\"\tplease";`, "say \"hi\"\tplease\n"},
+		{"suna `two\nlines`;", "two\nlines\n"},
+	}
+	for _, tt := range tests {
+		if got := runNPP(t, tt.code); got != tt.want {
+			t.Errorf("%s: got %q, want %q", tt.code, got, tt.want)
+		}
+	}
+
+	l := lexer.New("`never closed")
+	if tok := l.NextToken(); tok.Type != lexer.ILLEGAL {
+		t.Errorf("unterminated raw string: got %s, want ILLEGAL", tok.Type)
+	}
+}
+
+func TestElseIfChain(t *testing.T) {
+	chain := `agar x > 10 {
+    suna "big";
+} magar agar x > 5 {
+    suna "medium";
+} magar agar x > 0 {
+    suna "small";
+} magar {
+    suna "not positive";
+}`
+	tests := []struct {
+		x    string
+		want string
+	}{
+		{"20", "big\n"},
+		{"7", "medium\n"},
+		{"3", "small\n"},
+		{"-4", "not positive\n"},
+	}
+	for _, tt := range tests {
+		if got := runNPP(t, "sun x = "+tt.x+";\n"+chain); got != tt.want {
+			t.Errorf("x = %s: got %q, want %q", tt.x, got, tt.want)
+		}
+	}
+
+	// Without a final magar, nothing runs when no condition matches.
+	if got := runNPP(t, `sun x = 0; agar x > 1 { suna "a"; } magar agar x < -1 { suna "b"; } suna "after";`); got != "after\n" {
+		t.Errorf("got %q, want %q", got, "after\n")
+	}
+}
+
+func TestUnclosedBlockRecovery(t *testing.T) {
+	code := "sun x = 2;\nagar x > 1 {\n    suna \"inside\";\nsun y = x * 3;\nsuna y;\n"
+	p := parser.New(lexer.New(code), false)
+	var program *parser.Program
+	captureStdout(t, func() { program = p.ParseProgram() })
+
+	errs := p.Errors()
+	if len(errs) != 1 {
+		t.Fatalf("expected exactly one syntax error, got %v", errs)
+	}
+	if want := "Unclosed { opened at line 2, col 13"; !strings.HasPrefix(errs[0].Message, want) {
+		t.Errorf("error = %q, want it to start with %q", errs[0].Message, want)
+	}
+	if len(program.Statements) != 2 {
+		t.Fatalf("expected sun and agar statements, got %d", len(program.Statements))
+	}
+	block := program.Statements[1].(*parser.IfStatement).Consequence
+	if len(block.Statements) != 3 {
+		t.Errorf("statements after the missing } should still parse, got %d", len(block.Statements))
+	}
+}
+
+func TestEmptyBlocks(t *testing.T) {
+	tests := []struct {
+		code string
+		want string
+	}{
+		{`agar 1 > 0 { } suna "after";`, "after\n"},
+		{`agar 1 > 0 {} magar { suna "no"; }`, ""},
+		{`agar 1 < 0 { suna "no"; } magar {}`, ""},
+		{`agar 1 < 0 { } magar agar yas { } magar { }`, ""},
+		{`koshish { } pakad (e) { }`, ""},
+		{`koshish { suna 1 / 0; } pakad (e) { } aakhir { } suna "recovered";`, "recovered\n"},
+	}
+	for _, tt := range tests {
+		p := parser.New(lexer.New(tt.code), false)
+		var program *parser.Program
+		captureStdout(t, func() { program = p.ParseProgram() })
+		if len(p.Errors()) > 0 {
+			t.Errorf("%s: unexpected syntax errors %v", tt.code, p.Errors())
+			continue
+		}
+		got := captureStdout(t, func() { core.New().Interpret(program) })
+		if got != tt.want {
+			t.Errorf("%s: got %q, want %q", tt.code, got, tt.want)
+		}
+	}
+}
+
+func TestLintComparisonArithmetic(t *testing.T) {
+	code := `sun a = 5; sun b = 3;
+sun bigger = a > b;
+suna bigger + 1;
+sun total = [1, 2 * bigger];
+sun bigger = a - b;
+suna bigger + 1;
+agar bigger == 2 { suna "fine"; }
+`
+	p := parser.New(lexer.New(code), false)
+	warnings := lintProgram(p.ParseProgram())
+	if len(warnings) != 2 {
+		t.Fatalf("expected 2 warnings, got %v", warnings)
+	}
+	for idx, line := range []int{3, 4} {
+		if warnings[idx].Line != line {
+			t.Errorf("warning %d on line %d, want line %d", idx, warnings[idx].Line, line)
+		}
+	}
+	want := "bigger holds a comparison result (yas/nah), so + on it is probably a mistake"
+	if warnings[0].Message != want {
+		t.Errorf("message = %q, want %q", warnings[0].Message, want)
+	}
+
+	path := filepath.Join(t.TempDir(), "lint.npp")
+	if err := os.WriteFile(path, []byte(code), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	var status int
+	out := captureStdout(t, func() { status = lint([]string{path}) })
+	if status != 1 || strings.Count(out, "warning:") != 2 {
+		t.Errorf("lint returned %d with output %q", status, out)
+	}
+}
+
+func TestNumericSeparators(t *testing.T) {
+	valid := []struct {
+		code string
+		want string
+	}{
+		{`suna 1_000_000;`, "1000000\n"},
+		{`suna 1_000.5;`, "1000.5\n"},
+		{`suna 3.141_592;`, "3.141592\n"},
+		{`suna -1_0.2_5;`, "-10.25\n"},
+		{`sun x = 2_0; suna x + 1;`, "21\n"},
+	}
+	for _, tt := range valid {
+		if got := runNPP(t, tt.code); got != tt.want {
+			t.Errorf("%s: got %q, want %q", tt.code, got, tt.want)
+		}
+	}
+
+	for _, literal := range []string{"1_.5", "1._5", "1.5_", "1__000.0", "100_", "-1_.5"} {
+		p := parser.New(lexer.New("suna "+literal+";"), false)
+		captureStdout(t, func() { p.ParseProgram() })
+		errs := p.Errors()
+		want := "Invalid number " + strings.TrimPrefix(literal, "-")
+		if len(errs) == 0 || !strings.HasPrefix(errs[0].Message, want) {
+			t.Errorf("%s: got errors %v, want %q", literal, errs, want)
+		}
+	}
+}
+
+func TestJSONRoundTrip(t *testing.T) {
+	code := `sun data = {"name": "npp \"v2\"", "tags": ["fast", "sassy"], "meta": {"stars": 5, "ratio": 2.0, "ok": yas, "none": []}};
+sun text = to_json(data);
+suna text;
+suna from_json(text);
+suna to_json(from_json(text));
+suna from_json("[1, 2.5, 1e3, null, false]");`
+	want := `{"meta":{"none":[],"ok":true,"ratio":2.0,"stars":5},"name":"npp \"v2\"","tags":["fast","sassy"]}
+{"meta": {"none": [], "ok": yas, "ratio": 2.0, "stars": 5}, "name": "npp \"v2\"", "tags": ["fast", "sassy"]}
+{"meta":{"none":[],"ok":true,"ratio":2.0,"stars":5},"name":"npp \"v2\"","tags":["fast","sassy"]}
+[1, 2.5, 1000.0, khali, nah]
+`
+	if got := runNPP(t, code); got != want {
+		t.Errorf("got:\n%s\nwant:\n%s", got, want)
+	}
+
+	errorTests := []struct {
+		code string
+		want string
+	}{
+		{`suna to_json({1: "one"});`, "to_json: hash keys must be strings, got int 1"},
+		{`suna to_json([len]);`, "to_json: builtin values have no JSON form"},
+		{`suna from_json("[1, 2");`, "from_json: invalid JSON: unexpected end of JSON input"},
+		{`suna from_json("1 2");`, "from_json: invalid JSON: unexpected data after the top-level value"},
+		{`suna from_json(5);`, "from_json expects a string, got int"},
+	}
+	for _, tt := range errorTests {
+		if got := runNPP(t, tt.code); !strings.Contains(got, tt.want) {
+			t.Errorf("%s: got %q, want it to contain %q", tt.code, got, tt.want)
+		}
+	}
+}
+
+func TestOrderingMismatchedTypes(t *testing.T) {
+	tests := []struct {
+		code string
+		want string
+	}{
+		{`suna "10" < 5;`, "Error at line 1, col 12: Cannot order string and int with <\n"},
+		{`sun n = 3; suna n >= "3";`, "Error at line 1, col 20: Cannot order int and string with >=\n"},
+		{`suna yas > 1;`, "Error at line 1, col 11: Cannot order bool and int with >\n"},
+		// Other operators keep the generic message.
+		{`suna "10" - 5;`, "Error at line 1, col 12: Invalid operation - between 10 and 5\n"},
+	}
+	for _, tt := range tests {
+		if got := runNPP(t, tt.code); got != tt.want {
+			t.Errorf("%s: got %q, want %q", tt.code, got, tt.want)
+		}
+	}
+}
+
+func TestIndexOutOfRangeMessages(t *testing.T) {
+	tests := []struct {
+		code string
+		want string
+	}{
+		{`sun a = [1, 2, 3]; suna a[3];`, "Index 3 out of range for length 3 (valid: 0 to 2)"},
+		{`sun a = [1, 2, 3]; suna a[-5];`, "Index -5 out of range for length 3 (valid: 0 to 2)"},
+		{`suna [][0];`, "Index 0 out of range for length 0 (it is empty)"},
+		{`suna "héllo"[7];`, "Index 7 out of range for length 5 (valid: 0 to 4)"},
+		{`suna "héllo"[-1];`, "Index -1 out of range for length 5 (valid: 0 to 4)"},
+		{`suna "abc"["x"];`, "String index must be an int, got x"},
+	}
+	for _, tt := range tests {
+		if got := runNPP(t, tt.code); !strings.Contains(got, tt.want) {
+			t.Errorf("%s: got %q, want it to contain %q", tt.code, got, tt.want)
+		}
+	}
+
+	if got := runNPP(t, `sun s = "héllo"; suna s[1], s[4];`); got != "é o\n" {
+		t.Errorf("string indexing: got %q, want %q", got, "é o\n")
+	}
+}
+
+func TestIntWidth(t *testing.T) {
+	code := `sun max = 2147483647;
+suna max + 1;
+suna -2147483648 - 1;
+suna 65536 * 65536;
+suna -2147483648 / -1;
+suna 4294967298;`
+	tests := []struct {
+		width int
+		want  string
+	}{
+		{0, "2147483648\n-2147483649\n4294967296\n2147483648\n4294967298\n"},
+		{64, "2147483648\n-2147483649\n4294967296\n2147483648\n4294967298\n"},
+		{32, "-2147483648\n2147483647\n0\n-2147483648\n2\n"},
+	}
+	program := parser.New(lexer.New(code), false).ParseProgram()
+	for _, tt := range tests {
+		i := core.New()
+		i.IntWidth = tt.width
+		i.CaptureOutput()
+		i.Interpret(program)
+		if got := i.Output(); got != tt.want {
+			t.Errorf("IntWidth %d: got %q, want %q", tt.width, got, tt.want)
+		}
+	}
+}
+
+func TestTernaryEvaluatesOnlyTakenBranch(t *testing.T) {
+	tests := []struct {
+		code string
+		want string
+	}{
+		{`sun x = 5; suna x > 0 ? "pos" : "neg";`, "pos\n"},
+		{`sun x = -5; suna x > 0 ? "pos" : "neg";`, "neg\n"},
+		// The untaken branch refers to an undefined variable or would fail.
+		{`suna yas ? 1 : never_defined;`, "1\n"},
+		{`suna nah ? never_defined : 2;`, "2\n"},
+		{`sun d = 0; suna d == 0 ? 0 : 10 / d;`, "0\n"},
+		{`suna nah ? 1 : fhenk("boom");`, "Error at line 1, col 22: boom\n"},
+		// Ternaries nest to the right and bind looser than other operators.
+		{`sun n = 0; suna n > 0 ? "pos" : n < 0 ? "neg" : "zero";`, "zero\n"},
+		{`suna 1 + 1 == 2 ? 10 + 1 : 0;`, "11\n"},
+		{`suna {"k": yas ? 1 : 2}["k"], [nah ? 1 : 2][0];`, "1 2\n"},
+		{`suna (yas) ? "grouped" : "no";`, "grouped\n"},
+	}
+	for _, tt := range tests {
+		if got := runNPP(t, tt.code); got != tt.want {
+			t.Errorf("%s: got %q, want %q", tt.code, got, tt.want)
+		}
+	}
+
+	p := parser.New(lexer.New(`suna yas ? 1;`), false)
+	captureStdout(t, func() { p.ParseProgram() })
+	if errs := p.Errors(); len(errs) == 0 || !strings.HasPrefix(errs[0].Message, "Expected : in ternary") {
+		t.Errorf("missing : should be a syntax error, got %v", errs)
+	}
+}
+
+func TestErrorPositionBuiltins(t *testing.T) {
+	code := `sun x = 0;
+koshish {
+    suna 10 / x;
+} pakad (e) {
+    suna error_line(e), error_col(e);
+}
+koshish {
+    fhenk("custom");
+} pakad (e) {
+    suna "line " + type(e), error_line(e);
+}
+suna error_line(5);`
+	want := "3 14\nline error 8\nError at line 12, col 17: error_line expects an error, got int\n"
+	if got := runNPP(t, code); got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+}
+
+func TestWhileLoop(t *testing.T) {
+	tests := []struct {
+		code     string
+		expected string
+	}{
+		{`sun x = 0; grind x < 10 { sun x = x + 1; } suna x;`, "10\n"},
+		{`sun x = 0; grind x < 3 { suna x; sun x = x + 1; }`, "0\n1\n2\n"},
+		{`sun x = 5; grind x < 3 { suna "never"; } suna "done";`, "done\n"},
+		{`sun n = 3; grind n { suna n; sun n = n - 1; }`, "3\n2\n1\n"},
+		{`sun x = 0; grind x < "a" { sun x = x + 1; }`, "Error at line 1, col 21: Cannot order int and string with <\n"},
+	}
+	for _, tt := range tests {
+		output := runNPP(t, tt.code)
+		if output != tt.expected {
+			t.Errorf("%s\nGot:\n%q\nWant:%q", tt.code, output, tt.expected)
+		}
+	}
+}
+
+func TestLabeledBreakAndContinue(t *testing.T) {
+	code := `sun i = 0;
+bahar: grind i < 5 {
+    sun i = i + 1;
+    sun j = 0;
+    grind yas {
+        sun j = j + 1;
+        agar j == 2 { agla; }
+        agar i == 3 { tod bahar; }
+        agar j > 3 { tod; }
+        suna i, j;
+    }
+}
+suna "done", i, j;`
+	want := "1 1\n1 3\n2 1\n2 3\ndone 3 1\n"
+	if got := runNPP(t, code); got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+
+	code = `sun n = 0;
+bahar: grind n < 3 {
+    sun n = n + 1;
+    grind yas {
+        koshish {
+            agla bahar;
+        } aakhir {
+            suna "cleanup", n;
+        }
+    }
+}`
+	want = "cleanup 1\ncleanup 2\ncleanup 3\n"
+	if got := runNPP(t, code); got != want {
+		t.Errorf("agla through aakhir: got %q, want %q", got, want)
+	}
+
+	errorTests := []struct {
+		code string
+		want string
+	}{
+		{`tod;`, "tod used outside of a loop"},
+		{`grind yas { tod nowhere; }`, "Unknown loop label 'nowhere'"},
+		{`a: suna 1;`, "Expected a loop after label 'a:'"},
+		{`a: grind yas { a: grind yas { tod; } }`, "Label 'a' is already used by an enclosing loop"},
+	}
+	for _, tt := range errorTests {
+		p := parser.New(lexer.New(tt.code), false)
+		captureStdout(t, func() { p.ParseProgram() })
+		if errs := p.Errors(); len(errs) == 0 || !strings.HasPrefix(errs[0].Message, tt.want) {
+			t.Errorf("%s: got %v, want an error starting with %q", tt.code, errs, tt.want)
+		}
+	}
+}
+
+func TestCloneIsDeep(t *testing.T) {
+	i := core.New()
+	eval := func(code string) core.Object {
+		return i.Eval(parser.New(lexer.New(code), false).ParseProgram().Statements[0])
+	}
+	eval(`sun original = {"list": [1, [2, 3]], "name": "npp"};`)
+	copied := eval(`clone(original)`).(*core.HashObject)
+	if copied.String() != `{"list": [1, [2, 3]], "name": "npp"}` {
+		t.Fatalf("clone = %s", copied.String())
+	}
+
+	// Mutate the copy at every level; the language has no mutation yet, so do it from Go.
+	list := copied.Pairs[(&core.StringObject{Value: "list"}).HashKey()].Value.(*core.ArrayObject)
+	list.Elements[0] = &core.StringObject{Value: "changed"}
+	list.Elements[1].(*core.ArrayObject).Elements[0] = &core.StringObject{Value: "deep"}
+	copied.Set(&core.StringObject{Value: "extra"}, &core.StringObject{Value: "extra"}, core.TRUE)
+
+	if got := eval(`original`).String(); got != `{"list": [1, [2, 3]], "name": "npp"}` {
+		t.Errorf("original changed through its clone: %s", got)
+	}
+	if got := eval(`clone(5)`).String(); got != "5" {
+		t.Errorf("clone(5) = %s", got)
+	}
+}
+
+func TestBareBlockScope(t *testing.T) {
+	code := `sun outer = 1;
+{
+    sun inner = 2;
+    sun outer = outer + inner;
+    suna inner, outer;
+    {
+        sun deepest = 3;
+        suna inner + deepest;
+    }
+}
+suna outer;
+suna inner;`
+	want := "2 3\n5\n3\nError at line 12, col 12: Undefined variable inner\n"
+	if got := runNPP(t, code); got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+
+	if got := runNPP(t, `{ } { sun x = 1; } suna "ok";`); got != "ok\n" {
+		t.Errorf("empty and sibling blocks: got %q", got)
+	}
+}
+
+func TestBuiltinCallsInArithmetic(t *testing.T) {
+	tests := []struct {
+		code string
+		want string
+	}{
+		{`suna len("abc") * 2;`, "6\n"},
+		{`suna 1 + len([1, 2]) * len("ab");`, "5\n"},
+		{`sun a = [1, 2, 3]; suna a[len(a) - 1];`, "3\n"},
+		{`agar len("x") + 1 == 2 { suna "yes"; }`, "yes\n"},
+		{`suna len("ab") % 2 == 0 ? "even" : "odd";`, "even\n"},
+		{`suna -len("abc"), -len("abc") * 2, 10 - -len("ab");`, "-3 -6 12\n"},
+		{`sun a = [1.5]; suna -a[0];`, "-1.5\n"},
+		{`suna -type(1);`, "Error at line 1, col 7: Cannot negate string int\n"},
+	}
+	for _, tt := range tests {
+		if got := runNPP(t, tt.code); got != tt.want {
+			t.Errorf("%s: got %q, want %q", tt.code, got, tt.want)
+		}
+	}
+}
+
+func TestStartsWithEndsWith(t *testing.T) {
+	tests := []struct {
+		code string
+		want string
+	}{
+		{`suna starts_with("npp rocks", "npp"), starts_with("npp rocks", "rocks");`, "yas nah\n"},
+		{`suna ends_with("main.npp", ".npp"), ends_with("main.npp", ".go");`, "yas nah\n"},
+		{`suna starts_with("abc", ""), ends_with("", "");`, "yas yas\n"},
+		{`suna starts_with("ab", "abc"), ends_with("héllo", "llo");`, "nah yas\n"},
+		{`suna starts_with("abc", 1);`, "Error at line 1, col 18: starts_with expects two strings, got string and int\n"},
+		{`suna ends_with("abc");`, "Error at line 1, col 16: ends_with expects 2 argument(s), got 1\n"},
+	}
+	for _, tt := range tests {
+		if got := runNPP(t, tt.code); got != tt.want {
+			t.Errorf("%s: got %q, want %q", tt.code, got, tt.want)
+		}
+	}
+}
+
+func TestSquareRoots(t *testing.T) {
+	tests := []struct {
+		code string
+		want string
+	}{
+		{`suna isqrt(0), isqrt(1), isqrt(16), isqrt(17), isqrt(24), isqrt(25);`, "0 1 4 4 4 5\n"},
+		{`suna isqrt(9223372036854775807), isqrt(999999999999999999);`, "3037000499 999999999\n"},
+		{`suna sqrt(16), sqrt(2.25), sqrt(2);`, "4.0 1.5 1.4142135623730951\n"},
+		{`suna isqrt(-4);`, "Error at line 1, col 12: isqrt: negative number -4\n"},
+		{`suna isqrt(4.0);`, "Error at line 1, col 12: isqrt expects an int, got float\n"},
+		{`suna sqrt(-0.5);`, "Error at line 1, col 11: sqrt: negative number -0.5\n"},
+	}
+	for _, tt := range tests {
+		if got := runNPP(t, tt.code); got != tt.want {
+			t.Errorf("%s: got %q, want %q", tt.code, got, tt.want)
+		}
+	}
+}
+
+func TestConstantFolding(t *testing.T) {
+	tests := []struct {
+		code     string
+		ast      string
+		expected string
+	}{
+		{"suna 2 + 3;", "suna 5\n", "5\n"},
+		{"suna 1 + 2 * 3 - 4;", "suna 3\n", "3\n"},
+		{"suna -7 / 2, -7 % 2;", "suna -3, -1\n", "-3 -1\n"},
+		{"sun x = 1; suna x + 2 * 3;", "sun x = 1\nsuna (x + 6)\n", "7\n"},
+		{"suna 2 < 3;", "suna (2 < 3)\n", "yas\n"},
+		{"suna 1.5 + 1;", "suna (1.5 + 1)\n", "2.5\n"},
+		{"suna 1 / 0;", "suna (1 / 0)\n", "Error at line 1, col 9: Division by zero\n"},
+		{"suna 5 % 0;", "suna (5 % 0)\n", "Error at line 1, col 9: Modulo by zero\n"},
+		// Results past 32 bits depend on IntWidth, so they are left for runtime.
+		{"suna 65536 * 65536;", "suna (65536 * 65536)\n", "4294967296\n"},
+	}
+	for _, tt := range tests {
+		var program *parser.Program
+		captureStdout(t, func() { program = parser.New(lexer.New(tt.code), false).ParseProgram() })
+		if program.String() != tt.ast {
+			t.Errorf("%s\nGot AST:\n%q\nWant:%q", tt.code, program.String(), tt.ast)
+		}
+		if tt.expected == "" {
+			continue
+		}
+		output := runNPP(t, tt.code)
+		if output != tt.expected {
+			t.Errorf("%s\nGot:\n%q\nWant:%q", tt.code, output, tt.expected)
+		}
+	}
+}
+
+func TestMixedIntFloat(t *testing.T) {
+	tests := []struct {
+		code     string
+		expected string
+	}{
+		{"suna 3 == 3.0, 3.0 == 3, 3 != 3.0;", "yas yas nah\n"},
+		{"suna 3 < 3.5, 3.5 < 3, 2.5 >= 2, 2 <= 1.9;", "yas nah yas nah\n"},
+		{"suna 1 + 0.5, 0.5 + 1, 2 - 0.5, 3 * 1.5;", "1.5 1.5 1.5 4.5\n"},
+		{"suna 7 / 2.0, 7.0 / 2, 7 / 2;", "3.5 3.5 3\n"},
+		{"sun n = 4; sun avg = 10.0 / n; suna avg, type(avg);", "2.5 float\n"},
+		{"suna 1 / 0.0;", "Error at line 1, col 9: Division by zero\n"},
+		{"suna 1.5 % 2;", "Error at line 1, col 11: Invalid operation % between 1.5 and 2\n"},
+	}
+	for _, tt := range tests {
+		output := runNPP(t, tt.code)
+		if output != tt.expected {
+			t.Errorf("%s\nGot:\n%q\nWant:%q", tt.code, output, tt.expected)
+		}
+	}
+}
+
+func TestFoldedConstantKeepsPosition(t *testing.T) {
+	tests := []struct {
+		code  string
+		value int64
+		left  string // literal of the token the folded node should keep
+	}{
+		{"suna 2 + 3;", 5, "2"},
+		{"suna\n   7 * 6;", 42, "7"},
+		{"suna 1 + 2 * 3 - 4;", 3, "1"},
+		{"suna x, 10 % 4;", 2, "10"},
+		{"suna -8 / 2;", -4, "-"},
+	}
+	for _, tt := range tests {
+		var want lexer.Token
+		for l := lexer.New(tt.code); want.Literal != tt.left; {
+			want = l.NextToken()
+		}
+		program := parser.New(lexer.New(tt.code), false).ParseProgram()
+		stmt := program.Statements[0].(*parser.PrintStatement)
+		folded, ok := stmt.Values[len(stmt.Values)-1].(*parser.NumberLiteral)
+		if !ok {
+			t.Errorf("%q: got %s, want a folded literal", tt.code, program.String())
+			continue
+		}
+		got := folded.Token
+		if folded.Value != tt.value || got.Line != want.Line || got.Column != want.Column {
+			t.Errorf("%q: got %d at line %d, col %d, want %d at line %d, col %d", tt.code,
+				folded.Value, got.Line, got.Column, tt.value, want.Line, want.Column)
+		}
+	}
+}
+
+func TestWalkVisitsEveryNodeOnce(t *testing.T) {
+	code := `sun h = {"a": [1, 2.5]};
+suna h["a"][0], -len(h);
+agar yas { suna "x"; } magar { fhenk("no"); }
+koshish { bahar: grind nah ? 1 : 0 { tod bahar; } } pakad (e) { { suna e; } } aakhir { suna 1 < x; }
+glow f(a) { fhek a; }
+suna khali;`
+	program := parser.New(lexer.New(code), false).ParseProgram()
+	seen := make(map[parser.Node]int)
+	counts := make(map[string]int)
+	parser.Walk(program, func(node parser.Node) bool {
+		seen[node]++
+		counts[fmt.Sprintf("%T", node)]++
+		return true
+	})
+	for node, n := range seen {
+		if n != 1 {
+			t.Errorf("%T %s visited %d times", node, node.String(), n)
+		}
+	}
+	want := map[string]int{
+		"*parser.Program":               1,
+		"*parser.AssignmentStatement":   1,
+		"*parser.PrintStatement":        5,
+		"*parser.ExpressionStatement":   1,
+		"*parser.IfStatement":           1,
+		"*parser.TryStatement":          1,
+		"*parser.WhileStatement":        1,
+		"*parser.BranchStatement":       1,
+		"*parser.FunctionStatement":     1,
+		"*parser.ReturnStatement":       1,
+		"*parser.BlockStatement":        8,
+		"*parser.Identifier":            11,
+		"*parser.NumberLiteral":         5,
+		"*parser.FloatLiteral":          1,
+		"*parser.StringLiteral":         4,
+		"*parser.BooleanLiteral":        2,
+		"*parser.NullLiteral":           1,
+		"*parser.ArrayLiteral":          1,
+		"*parser.HashLiteral":           1,
+		"*parser.CallExpression":        2,
+		"*parser.IndexExpression":       2,
+		"*parser.BinaryExpression":      1,
+		"*parser.PrefixExpression":      1,
+		"*parser.ConditionalExpression": 1,
+	}
+	for typ, n := range want {
+		if counts[typ] != n {
+			t.Errorf("%s: visited %d, want %d", typ, counts[typ], n)
+		}
+	}
+	if len(counts) != len(want) {
+		t.Errorf("visited node types %v, want %v", counts, want)
+	}
+
+	// Returning false skips the children, so only the statements are seen.
+	visited := 0
+	parser.Walk(program, func(node parser.Node) bool {
+		visited++
+		_, isProgram := node.(*parser.Program)
+		return isProgram
+	})
+	if visited != 1+len(program.Statements) {
+		t.Errorf("pruned walk visited %d nodes, want %d", visited, 1+len(program.Statements))
+	}
+}
+
+func TestFmtDuration(t *testing.T) {
+	tests := []struct {
+		code string
+		want string
+	}{
+		{`suna fmt_duration(0), fmt_duration(250), fmt_duration(999);`, "0ms 250ms 999ms\n"},
+		{`suna fmt_duration(1000), fmt_duration(45999);`, "1s 45s\n"},
+		{`suna fmt_duration(83000), fmt_duration(83500), fmt_duration(60000);`, "1m 23s 1m 23s 1m 0s\n"},
+		{`suna fmt_duration(3600000), fmt_duration(3723000), fmt_duration(90000000);`, "1h 0m 0s 1h 2m 3s 25h 0m 0s\n"},
+		{`suna fmt_duration(-1);`, "Error at line 1, col 19: fmt_duration: negative duration -1\n"},
+		{`suna fmt_duration(1.5);`, "Error at line 1, col 19: fmt_duration expects an int, got float\n"},
+	}
+	for _, tt := range tests {
+		if got := runNPP(t, tt.code); got != tt.want {
+			t.Errorf("%s: got %q, want %q", tt.code, got, tt.want)
+		}
+	}
+}
+
+func TestDestructuringAssignment(t *testing.T) {
+	tests := []struct {
+		code     string
+		expected string
+	}{
+		{`sun h = {"a": 1}; sun k, v = entries(h)[0]; suna k, v;`, "a 1\n"},
+		{`sun a, b = [1, 2]; sun a, b = [b, a]; suna a, b;`, "2 1\n"},
+		{`sun x, y, z = [1, "two", yas]; suna z, y, x;`, "yas two 1\n"},
+		{`sun a, b = 5;`, "Error at line 1, col 5: Cannot unpack int into 2 names\n"},
+		{`sun a, b = [1, 2, 3];`, "Error at line 1, col 5: Cannot unpack 3 value(s) into 2 names\n"},
+		{`sun a = 0; koshish { sun a, b = [1]; } pakad (e) { suna a; }`, "0\n"},
+		{`glow f() { fhek 1, "two"; } sun x, y = f(); suna x, y;`, "1 two\n"},
+		{`glow swap(a, b) { fhek b, a } sun a, b = swap(1, 2); suna a, b;`, "2 1\n"},
+		{`glow f() { fhek 1, 2, 3; } suna f(), len(f());`, "[1, 2, 3] 3\n"},
+		{`glow f() { fhek 1, 2; } sun x, y, z = f();`, "Error at line 1, col 29: Cannot unpack 2 value(s) into 3 names\n"},
+	}
+	for _, tt := range tests {
+		output := runNPP(t, tt.code)
+		if output != tt.expected {
+			t.Errorf("%s\nGot:\n%q\nWant:%q", tt.code, output, tt.expected)
+		}
+	}
+	for code, want := range map[string]string{
+		`sun a, a = [1, 2];`: "Variable 'a' appears twice in one sun",
+		`sun a, 1 = [1, 2];`: "Expected identifier after , in sun, got INT",
+	} {
+		var errs []parser.ParseError
+		captureStdout(t, func() {
+			p := parser.New(lexer.New(code), false)
+			p.ParseProgram()
+			errs = p.Errors()
+		})
+		if len(errs) == 0 || !strings.HasPrefix(errs[0].Message, want) {
+			t.Errorf("%s: got errors %+v, want first to start with %q", code, errs, want)
+		}
+	}
+	program := parser.New(lexer.New(`sun k, v = pair;`), false).ParseProgram()
+	if got := program.String(); got != "sun k, v = pair\n" {
+		t.Errorf("got AST %q", got)
+	}
+	program = parser.New(lexer.New(`glow f() { fhek a, b + 1; }`), false).ParseProgram()
+	body := program.Statements[0].(*parser.FunctionStatement).Body
+	if got := body.Statements[0].String(); got != "fhek [a, (b + 1)]" {
+		t.Errorf("got AST %q", got)
+	}
+}
+
+func TestBoolBuiltin(t *testing.T) {
+	tests := []struct {
+		code string
+		want string
+	}{
+		{`suna bool(1), bool(-3), bool(0);`, "yas yas nah\n"},
+		{`suna bool(0.5), bool(0.0);`, "yas nah\n"},
+		{`suna bool("x"), bool("");`, "yas nah\n"},
+		{`suna bool(yas), bool(nah), bool(1 > 2);`, "yas nah nah\n"},
+		{`suna bool([1, 2]), bool([]), bool({"a": 1});`, "yas nah yas\n"},
+		{`suna bool({}), bool([0]), bool([khali]), bool({"": nah});`, "nah yas yas yas\n"},
+		{`sun xs = [1]; agar bool(xs) == yas { suna "non-empty"; }`, "non-empty\n"},
+		{`suna bool(from_json("null"));`, "nah\n"},
+		{`sun x = 7; agar bool(x) == yas { suna "same as agar x"; }`, "same as agar x\n"},
+		{`suna bool(1, 2);`, "Error at line 1, col 11: bool expects 1 argument(s), got 2\n"},
+	}
+	for _, tt := range tests {
+		if got := runNPP(t, tt.code); got != tt.want {
+			t.Errorf("%s: got %q, want %q", tt.code, got, tt.want)
+		}
+	}
+}
+
+func TestStrictSemicolons(t *testing.T) {
+	tests := []struct {
+		code   string
+		strict int // syntax errors in strict mode; lenient mode never has any
+	}{
+		{"sun a = 1; sun b = 2; suna a + b;", 0},
+		{"sun a = 1\nsun b = 2\nsuna a + b", 0},
+		{"sun a = 1 // one\nsuna a", 0},
+		{"agar yas { suna 1 } suna 2", 0},
+		{"grind nah { } { suna 1 } suna 2;", 0},
+		{"sun a = 1 sun b = 2;", 1},
+		{"suna 1 suna 2 suna 3", 2},
+		{"agar yas { suna 1 suna 2 }", 1},
+		{"sun x = 1\nsuna x suna x", 1},
+	}
+	for _, tt := range tests {
+		for _, strict := range []bool{false, true} {
+			var errs []parser.ParseError
+			output := captureStdout(t, func() {
+				p := parser.New(lexer.New(tt.code), false)
+				p.StrictSemicolons = strict
+				p.ParseProgram()
+				errs = p.Errors()
+			})
+			want := 0
+			if strict {
+				want = tt.strict
+			}
+			if len(errs) != want {
+				t.Errorf("%q (strict %v): got %d error(s) %q, want %d", tt.code, strict, len(errs), output, want)
+			}
+			for _, err := range errs {
+				if !strings.HasPrefix(err.Message, "Missing ; before suna on the same line") &&
+					!strings.HasPrefix(err.Message, "Missing ; before sun on the same line") {
+					t.Errorf("%q: unexpected error %q", tt.code, err.Message)
+				}
+			}
+		}
+	}
+
+	dir := t.TempDir()
+	file := filepath.Join(dir, "merged.npp")
+	if err := os.WriteFile(file, []byte("sun a = 1 sun b = 2\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	var status int
+	captureStdout(t, func() { status = check([]string{file}) })
+	if status != 0 {
+		t.Errorf("check without -strict-semicolons = %d, want 0", status)
+	}
+	strictSemicolons = true
+	defer func() { strictSemicolons = false }()
+	output := captureStdout(t, func() { status = check([]string{file}) })
+	if status != 1 || !strings.Contains(output, "Missing ; before sun") {
+		t.Errorf("check with -strict-semicolons = %d, output %q; want 1 and a missing ; error", status, output)
+	}
+}
+
+func TestLooseEq(t *testing.T) {
+	tests := []struct {
+		code string
+		want string
+	}{
+		{`suna loose_eq("5", 5);`, "yas\n"},
+		{`suna "5" == 5;`, "Error at line 1, col 11: Invalid operation == between 5 and 5\n"},
+		{`suna loose_eq(5, " 5 "), loose_eq("2.50", 2.5), loose_eq(3, 3.0);`, "yas yas yas\n"},
+		{`suna loose_eq("5", 6), loose_eq("five", 5), loose_eq("", 0);`, "nah nah nah\n"},
+		{`suna loose_eq("1.0", "1"), loose_eq("a", "a");`, "nah yas\n"},
+		{`suna loose_eq(yas, 1), loose_eq(nah, 0), loose_eq(yas, "yas"), loose_eq(nah, nah);`, "nah nah nah yas\n"},
+		{`suna loose_eq([1, "a"], [1, "a"]), loose_eq([1], [2]), loose_eq({"k": 1}, {"k": 1});`, "yas nah yas\n"},
+		{`suna loose_eq([1], "[1]");`, "nah\n"},
+		{`suna loose_eq(1);`, "Error at line 1, col 15: loose_eq expects 2 argument(s), got 1\n"},
+	}
+	for _, tt := range tests {
+		if got := runNPP(t, tt.code); got != tt.want {
+			t.Errorf("%s: got %q, want %q", tt.code, got, tt.want)
+		}
+	}
+}
+
+func TestRepeatLoop(t *testing.T) {
+	tests := []struct {
+		code     string
+		expected string
+	}{
+		{`sun n = 0; baar 5 { sun n = n + 1; } suna n;`, "5\n"},
+		{`sun n = 0; baar 0 { sun n = n + 1; } suna n;`, "0\n"},
+		{`sun k = 3; baar k { suna "hi"; sun k = 10; }`, "hi\nhi\nhi\n"},
+		{`sun n = 0; baar 10 { sun n = n + 1; agar n == 2 { tod; } } suna n;`, "2\n"},
+		{`sun n = 0; baar 4 { sun n = n + 1; agar n % 2 == 0 { agla; } suna n; }`, "1\n3\n"},
+		{`bahar: baar 3 { baar 3 { suna "x"; tod bahar; } }`, "x\n"},
+		{`baar 2.0 { suna "no"; }`, "Error at line 1, col 6: baar expects an int count, got float\n"},
+		{`baar -1 { suna "no"; }`, "Error at line 1, col 6: baar count cannot be negative, got -1\n"},
+	}
+	for _, tt := range tests {
+		output := runNPP(t, tt.code)
+		if output != tt.expected {
+			t.Errorf("%s\nGot:\n%q\nWant:%q", tt.code, output, tt.expected)
+		}
+	}
+}
+
+func TestArrayEqualityAndSame(t *testing.T) {
+	tests := []struct {
+		code     string
+		expected string
+	}{
+		{`sun a = [1, [2, "x"]]; sun b = [1, [2, "x"]]; suna a == b, same(a, b);`, "yas nah\n"},
+		{`sun a = [1, 2]; sun b = a; suna a == b, same(a, b);`, "yas yas\n"},
+		{`sun a = [1, 2]; suna a == clone(a), same(a, clone(a));`, "yas nah\n"},
+		{`suna [1, 2] == [2, 1], [1] != [1, 1], [1] == [1.0], [1] == ["1"];`, "nah yas yas nah\n"},
+		{`sun h = {"a": 1, "b": [2]}; suna h == {"b": [2], "a": 1}, h == {"a": 1}, same(h, h);`, "yas nah yas\n"},
+		{`suna [] == {}, same(1, 1), same("a", "b");`, "nah yas nah\n"},
+		{`suna [1] == 1;`, "Error at line 1, col 11: Invalid operation == between [1] and 1\n"},
+	}
+	for _, tt := range tests {
+		output := runNPP(t, tt.code)
+		if output != tt.expected {
+			t.Errorf("%s\nGot:\n%q\nWant:%q", tt.code, output, tt.expected)
+		}
+	}
+}
+
+func TestDigitHelpers(t *testing.T) {
+	tests := []struct {
+		code string
+		want string
+	}{
+		{`suna digit_sum(0), digit_sum(7), digit_sum(1234), digit_sum(9999);`, "0 7 10 36\n"},
+		{`suna num_digits(0), num_digits(7), num_digits(10), num_digits(1234);`, "1 1 2 4\n"},
+		{`suna digit_sum(9223372036854775807), num_digits(9223372036854775807);`, "88 19\n"},
+		{`suna digit_sum(-12);`, "Error at line 1, col 16: digit_sum: negative number -12\n"},
+		{`suna num_digits(-1);`, "Error at line 1, col 17: num_digits: negative number -1\n"},
+		{`suna num_digits("12");`, "Error at line 1, col 17: num_digits expects an int, got string\n"},
+	}
+	for _, tt := range tests {
+		if got := runNPP(t, tt.code); got != tt.want {
+			t.Errorf("%s: got %q, want %q", tt.code, got, tt.want)
+		}
+	}
+}
+
+func TestNewWithBuiltins(t *testing.T) {
+	shout := func(tok lexer.Token, args ...core.Object) core.Object {
+		return &core.StringObject{Value: strings.ToUpper(args[0].String()) + "!"}
+	}
+	tests := []struct {
+		code string
+		want string
+	}{
+		{`suna shout("hi");`, "HI!\n"},
+		{`suna type(shout);`, "Error at line 1, col 11: Undefined variable type\n"},
+		{`suna len("abc");`, "Error at line 1, col 10: Undefined variable len\n"},
+		{`suna depth();`, "Error at line 1, col 12: Undefined variable depth\n"},
+	}
+	for _, tt := range tests {
+		i := core.NewWithBuiltins(map[string]core.BuiltinFunc{"shout": shout})
+		i.CaptureOutput()
+		i.Interpret(parser.New(lexer.New(tt.code), false).ParseProgram())
+		if got := i.Output(); got != tt.want {
+			t.Errorf("%s: got %q, want %q", tt.code, got, tt.want)
+		}
+	}
+}
+
+func TestDisableBuiltins(t *testing.T) {
+	tests := []struct {
+		code string
+		want string
+	}{
+		{`suna len("abc"), type(1);`, "3 int\n"},
+		{`suna to_json([1]);`, "Error at line 1, col 14: to_json is disabled in this interpreter\n"},
+		{`koshish { from_json("1"); } pakad (e) { suna "caught:", e; }`, "caught: Error at line 1, col 21: from_json is disabled in this interpreter\n"},
+		{`sun from_json = 5; suna from_json;`, "5\n"},
+	}
+	for _, tt := range tests {
+		i := core.New()
+		i.DisableBuiltins("to_json", "from_json", "read_file")
+		i.CaptureOutput()
+		i.Interpret(parser.New(lexer.New(tt.code), false).ParseProgram())
+		if got := i.Output(); got != tt.want {
+			t.Errorf("%s: got %q, want %q", tt.code, got, tt.want)
+		}
+	}
+}
+
+func TestToJSONSortsKeys(t *testing.T) {
+	tests := []struct {
+		code string
+		want string
+	}{
+		{`suna to_json({"b": 1, "a": 2, "c": 3});`, `{"a":2,"b":1,"c":3}` + "\n"},
+		{`suna to_json({"z": {"y": 1, "x": 2}, "B": 0, "a": 0});`, `{"B":0,"a":0,"z":{"x":2,"y":1}}` + "\n"},
+	}
+	for _, tt := range tests {
+		if got := runNPP(t, tt.code); got != tt.want {
+			t.Errorf("%s: got %q, want %q", tt.code, got, tt.want)
+		}
+	}
+}
+
+func TestTokensBuiltin(t *testing.T) {
+	tests := []struct {
+		code string
+		want string
+	}{
+		{`suna tokens("sun x = 4 + y;");`, `[["SUN", "sun"], ["IDENT", "x"], ["=", "="], ["INT", "4"], ["+", "+"], ["IDENT", "y"], [";", ";"]]` + "\n"},
+		{`suna tokens("agar s >= \"hi\" // note");`, `[["AGAR", "agar"], ["IDENT", "s"], [">=", ">="], ["STRING", "hi"]]` + "\n"},
+		{`suna len(tokens("")), tokens("@")[0][0];`, "0 ILLEGAL\n"},
+		{`suna tokens(1);`, "Error at line 1, col 13: tokens expects a string, got int\n"},
+	}
+	for _, tt := range tests {
+		i := core.New()
+		i.EnableMetaBuiltins()
+		i.CaptureOutput()
+		i.Interpret(parser.New(lexer.New(tt.code), false).ParseProgram())
+		if got := i.Output(); got != tt.want {
+			t.Errorf("%s: got %q, want %q", tt.code, got, tt.want)
+		}
+	}
+	if got := runNPP(t, `suna tokens("x");`); got != "Error at line 1, col 13: Undefined variable tokens\n" {
+		t.Errorf("tokens without EnableMetaBuiltins: got %q", got)
+	}
+}
+
+func TestEvalBuiltin(t *testing.T) {
+	tests := []struct {
+		code string
+		want string
+	}{
+		{`sun x = 2; suna eval("x * 21");`, "42\n"},
+		{`suna eval("sun y = 5"); suna y;`, "khali\n5\n"},
+		{`suna eval("sun a = 1; a + 1"), eval(""), type(eval("[1]"));`, "2 khali array\n"},
+		{`suna eval("sun = 1");`, "Error at line 1, col 11: eval: syntax error at line 1, col 6: Expected identifier after SUN, got = // My grandma codes better!\n"},
+		{`koshish { eval("1 / 0"); } pakad (e) { suna "caught", error_col(e); }`, "caught 4\n"},
+		{`suna eval(1);`, "Error at line 1, col 11: eval expects a string, got int\n"},
+	}
+	for _, tt := range tests {
+		i := core.New()
+		i.EnableMetaBuiltins()
+		i.CaptureOutput()
+		i.Interpret(parser.New(lexer.New(tt.code), false).ParseProgram())
+		if got := i.Output(); got != tt.want {
+			t.Errorf("%s: got %q, want %q", tt.code, got, tt.want)
+		}
+	}
+	if got := runNPP(t, `suna eval("1");`); got != "Error at line 1, col 11: Undefined variable eval\n" {
+		t.Errorf("eval without EnableMetaBuiltins: got %q", got)
+	}
+}
+
+func TestPassStatement(t *testing.T) {
+	tests := []struct {
+		code     string
+		expected string
+	}{
+		{`agar yas { chhod } suna "after";`, "after\n"},
+		{`agar nah { suna "no"; } magar { chhod; }`, ""},
+		{`sun n = 0; grind n < 3 { sun n = n + 1; chhod; } suna n;`, "3\n"},
+		{`chhod; koshish { chhod } pakad (e) { chhod } aakhir { suna "done"; }`, "done\n"},
+	}
+	for _, tt := range tests {
+		output := runNPP(t, tt.code)
+		if output != tt.expected {
+			t.Errorf("%s\nGot:\n%q\nWant:%q", tt.code, output, tt.expected)
+		}
+	}
+	program := parser.New(lexer.New(`agar yas { chhod }`), false).ParseProgram()
+	ifStmt := program.Statements[0].(*parser.IfStatement)
+	if _, ok := ifStmt.Consequence.Statements[0].(*parser.PassStatement); !ok || len(ifStmt.Consequence.Statements) != 1 {
+		t.Errorf("got block %v, want a single PassStatement", ifStmt.Consequence.Statements)
+	}
+}
+
+func TestGcdLcm(t *testing.T) {
+	tests := []struct {
+		code string
+		want string
+	}{
+		{`suna gcd(12, 18), gcd(17, 5), gcd(7, 7), gcd(1, 100);`, "6 1 7 1\n"},
+		{`suna lcm(4, 6), lcm(17, 5), lcm(7, 7), lcm(1, 100);`, "12 85 7 100\n"},
+		{`suna gcd(0, 9), gcd(9, 0), gcd(0, 0), lcm(0, 9), lcm(0, 0);`, "9 9 0 0 0\n"},
+		{`suna gcd(-12, 18), gcd(12, -18), lcm(-4, 6), lcm(-4, -6);`, "6 6 12 12\n"},
+		{`suna lcm(4611686018427387904, 3);`, "Error at line 1, col 10: lcm: result overflows an int\n"},
+		{`suna gcd(-9223372036854775807 - 1, 0);`, "Error at line 1, col 10: gcd: result overflows an int\n"},
+		{`suna gcd(-9223372036854775807 - 1, 6);`, "2\n"},
+		{`suna gcd(1.5, 3);`, "Error at line 1, col 10: gcd expects two ints, got float and int\n"},
+	}
+	for _, tt := range tests {
+		if got := runNPP(t, tt.code); got != tt.want {
+			t.Errorf("%s: got %q, want %q", tt.code, got, tt.want)
+		}
+	}
+}
+
+func TestZip(t *testing.T) {
+	tests := []struct {
+		code string
+		want string
+	}{
+		{`suna zip([1, 2, 3], ["a", "b"]);`, `[[1, "a"], [2, "b"]]` + "\n"},
+		{`suna zip(["x"], [yas, nah]), zip([], [1]);`, `[["x", yas]] []` + "\n"},
+		{`sun k, v = zip(["a"], [1])[0]; suna k, v;`, "a 1\n"},
+		{`suna zip([1], "ab");`, "Error at line 1, col 10: zip expects two arrays, got array and string\n"},
+	}
+	for _, tt := range tests {
+		if got := runNPP(t, tt.code); got != tt.want {
+			t.Errorf("%s: got %q, want %q", tt.code, got, tt.want)
+		}
+	}
+}
+
+func TestUserFunctions(t *testing.T) {
+	tests := []struct {
+		code     string
+		expected string
+	}{
+		{`glow add(a, b) { fhek a + b } suna add(2, 3);`, "5\n"},
+		{`glow fact(n) {
+    agar n <= 1 { fhek 1; }
+    fhek n * fact(n - 1);
+}
+suna fact(10);`, "3628800\n"},
+		{`glow d() { fhek depth(); } suna depth(), d();`, "0 1\n"},
+		{`glow hi() { suna "hi"; } suna hi();`, "hi\nkhali\n"},
+		{`glow f() { fhek; suna "unreachable"; } suna f();`, "khali\n"},
+		{`glow first(x) { grind yas { agar x > 3 { fhek x; } sun x = x + 1; } } suna first(0);`, "4\n"},
+		{`glow f() { koshish { fhek 1; } aakhir { suna "aakhir"; } } suna f();`, "aakhir\n1\n"},
+		{`sun n = 0; glow bump() { sun n = n + 1; } bump(); bump(); suna n;`, "2\n"},
+		{`sun x = 1; glow shadow(x) { fhek x * 10; } suna shadow(5), x;`, "50 1\n"},
+		{`glow outer() { glow inner() { fhek 7; } fhek inner; } suna outer()();`, "7\n"},
+		{`glow add(a, b) { fhek a + b; } suna type(add), add;`, "function glow add\n"},
+		{`glow add(a, b) { fhek a + b; } suna add(1);`, "Error at line 1, col 41: add expects 2 argument(s), got 1\n"},
+		{`glow f() { fhek 1 / 0; } suna f();`, "Error at line 1, col 20: Division by zero\n"},
+		{`glow loop(n) { fhek loop(n + 1); } suna loop(0);`, "Error at line 1, col 26: Maximum call depth of 10000 exceeded in loop\n"},
+	}
+	for _, tt := range tests {
+		output := runNPP(t, tt.code)
+		if output != tt.expected {
+			t.Errorf("%s\nGot:\n%q\nWant:%q", tt.code, output, tt.expected)
+		}
+	}
+	for code, want := range map[string]string{
+		`fhek 1;`:                         "fhek used outside of a function",
+		`grind yas { glow f() { tod; } }`: "tod used outside of a loop",
+		`glow f(a, a) { }`:                "Parameter 'a' appears twice in glow f",
+		`glow (a) { }`:                    "Expected function name after glow, got (",
+		`glow f(a b) { }`:                 "Expected , or ) in parameters, got IDENT",
+		`glow f() fhek 1;`:                "Expected { after parameters, got FHEK",
+	} {
+		var errs []parser.ParseError
+		captureStdout(t, func() {
+			p := parser.New(lexer.New(code), false)
+			p.ParseProgram()
+			errs = p.Errors()
+		})
+		if len(errs) == 0 || !strings.HasPrefix(errs[0].Message, want) {
+			t.Errorf("%s: got errors %+v, want first to start with %q", code, errs, want)
+		}
+	}
+	program := parser.New(lexer.New(`glow add(a, b) { fhek a + b; }`), false).ParseProgram()
+	if got := program.String(); got != "glow add(a, b) { ... }\n" {
+		t.Errorf("got AST %q", got)
+	}
+}
+
+func TestNotOperator(t *testing.T) {
+	tests := []struct {
+		code     string
+		expected string
+	}{
+		{`suna !"", !"x";`, "yas nah\n"},
+		{`suna ![], ![1, 2], !{"a": 1};`, "yas nah nah\n"},
+		{`sun h = {}; suna ![0], !h, !!h;`, "nah yas nah\n"},
+		{`sun items = [1]; agar items { suna "some"; } sun items = []; agar !items { suna "none"; }`, "some\nnone\n"},
+		{`suna !0, !5, !-3, !0.0;`, "yas nah nah yas\n"},
+		{`sun nothing = from_json("null"); suna !yas, !nah, !nothing;`, "nah yas yas\n"},
+		{`suna !!3, !!"", !!!0;`, "yas nah yas\n"},
+		{`sun done = nah; agar !done { suna "not done"; }`, "not done\n"},
+		{`suna !1 == nah;`, "yas\n"},
+		{`suna type(!"x");`, "bool\n"},
+		{`suna !missing;`, "Error at line 1, col 15: Undefined variable missing\n"},
+		{`sun x = 1; sun y = 2; agar !(x == y) { suna "differ"; }`, "differ\n"},
+		{`sun flag = yas; suna !flag, !!flag;`, "nah yas\n"},
+	}
+	for _, tt := range tests {
+		output := runNPP(t, tt.code)
+		if output != tt.expected {
+			t.Errorf("%s\nGot:\n%q\nWant:%q", tt.code, output, tt.expected)
+		}
+	}
+	for code, want := range map[string]string{
+		`suna !!x;`:       "suna (!(!x))\n",
+		`suna !a == b;`:   "suna ((!a) == b)\n",
+		`suna !(a == b);`: "suna (!(a == b))\n",
+		`suna !-x;`:       "suna (!(-x))\n",
+	} {
+		if got := parser.New(lexer.New(code), false).ParseProgram().String(); got != want {
+			t.Errorf("%s: got AST %q, want %q", code, got, want)
+		}
+	}
+}
+
+func TestEnumerate(t *testing.T) {
+	tests := []struct {
+		code     string
+		expected string
+	}{
+		{`suna enumerate(["a", "b", "c"]);`, "[[0, \"a\"], [1, \"b\"], [2, \"c\"]]\n"},
+		{`suna enumerate([]);`, "[]\n"},
+		{`sun pairs = enumerate([10, 20]); sun n = 0;
+grind n < len(pairs) {
+    sun idx, value = pairs[n];
+    suna idx, value;
+    sun n = n + 1;
+}`, "0 10\n1 20\n"},
+		{`suna enumerate("ab");`, "Error at line 1, col 16: enumerate expects an array, got string\n"},
+		{`suna enumerate();`, "Error at line 1, col 16: enumerate expects 1 argument(s), got 0\n"},
+	}
+	for _, tt := range tests {
+		output := runNPP(t, tt.code)
+		if output != tt.expected {
+			t.Errorf("%s\nGot:\n%q\nWant:%q", tt.code, output, tt.expected)
+		}
+	}
+}
+
+func TestParenthesizedGrouping(t *testing.T) {
+	tests := []struct {
+		code     string
+		expected string
+	}{
+		{`suna (2 + 3) * 4, 2 + 3 * 4;`, "20 14\n"},
+		{`sun x = 2; sun y = 3; suna ((x + 1) * (y - 1)) % 4;`, "2\n"},
+		{`sun x = 2; sun y = 3; suna -(x + y), !(x == y);`, "-5 yas\n"},
+		{`sun x = 2; agar !(x == 3) { suna "differ"; }`, "differ\n"},
+		{`suna (((7)));`, "7\n"},
+		{`sun a = [1, 2]; suna (a)[1], (len)(a);`, "2 2\n"},
+		{`sun x = 5; suna (x + 5) * 2 == 20 ? "twenty" : "nope";`, "twenty\n"},
+	}
+	for _, tt := range tests {
+		output := runNPP(t, tt.code)
+		if output != tt.expected {
+			t.Errorf("%s\nGot:\n%q\nWant:%q", tt.code, output, tt.expected)
+		}
+	}
+	var errs []parser.ParseError
+	captureStdout(t, func() {
+		p := parser.New(lexer.New("sun z = 1;\nsun w = (2 + 3;"), false)
+		p.ParseProgram()
+		errs = p.Errors()
+	})
+	want := "Expected ) to close ( opened at line 2, col 10, got ;"
+	if len(errs) == 0 || !strings.HasPrefix(errs[0].Message, want) || errs[0].Line != 2 {
+		t.Errorf("got errors %+v, want first on line 2 to start with %q", errs, want)
+	}
+	program := parser.New(lexer.New(`sun v = (a + b) * c;`), false).ParseProgram()
+	if got := program.String(); got != "sun v = ((a + b) * c)\n" {
+		t.Errorf("got AST %q", got)
+	}
+}
+
+func TestHexEscapes(t *testing.T) {
+	tests := []struct {
+		code     string
+		expected string
+	}{
+		{`suna "\x41";`, "A\n"},
+		{`suna "\x48\x69!", "\x7a\x7A";`, "Hi! zz\n"},
+		{`suna len("\x00"), len("a\x0ab");`, "1 3\n"},
+		{"suna `\\x41`;", "\\x41\n"},
+	}
+	for _, tt := range tests {
+		output := runNPP(t, tt.code)
+		if output != tt.expected {
+			t.Errorf("%s\nGot:\n%q\nWant:%q", tt.code, output, tt.expected)
+		}
+	}
+	for _, input := range []string{`"\x"`, `"\x4"`, `"\xZZ"`, `"\x4g"`, `"ok \x"`} {
+		tok := lexer.New(input + "; suna 1").NextToken()
+		if tok.Type != lexer.ILLEGAL {
+			t.Errorf("%s lexed as %s %q, want ILLEGAL", input, tok.Type, tok.Literal)
+		}
+	}
+	l := lexer.New(`"\xZZ" 7`)
+	l.NextToken()
+	if tok := l.NextToken(); tok.Type != lexer.INT || tok.Literal != "7" {
+		t.Errorf("after a bad escape got %s %q, want INT 7", tok.Type, tok.Literal)
+	}
+}
+
+func TestAssert(t *testing.T) {
+	tests := []struct {
+		code     string
+		expected string
+	}{
+		{`assert(1 + 1 == 2); assert("x", "non-empty"); suna "ok";`, "ok\n"},
+		{`suna assert(yas);`, "khali\n"},
+		{`assert(1 > 2);`, "Error at line 1, col 8: Assertion failed\n"},
+		{`sun a = [1]; assert(len(a) == 2, "want two");`, "Error at line 1, col 21: Assertion failed: want two\n"},
+		{`koshish { assert(nah, "caught"); } pakad (e) { suna e; }`, "Error at line 1, col 18: Assertion failed: caught\n"},
+		{`assert();`, "Error at line 1, col 8: assert expects 1 or 2 argument(s), got 0\n"},
+	}
+	for _, tt := range tests {
+		output := runNPP(t, tt.code)
+		if output != tt.expected {
+			t.Errorf("%s\nGot:\n%q\nWant:%q", tt.code, output, tt.expected)
+		}
+	}
+}
+
+func TestRunSuite(t *testing.T) {
+	dir := t.TempDir()
+	files := map[string]string{
+		"math_test.npp":   "sun a = [1, 2];\nassert(len(a) == 2);\nsuna \"hidden\";",
+		"broken_test.npp": "suna \"before\";\nassert(1 + 1 == 3, \"math is broken\");\nsuna \"after\";",
+		"helper.npp":      "assert(nah);",
+		"notes.txt":       "not a script",
+	}
+	for name, content := range files {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	var status int
+	output := captureStdout(t, func() { status = runSuite(dir, "*_test.npp") })
+	expected := "FAIL " + filepath.Join(dir, "broken_test.npp") + "\n" +
+		"    before\n" +
+		"    Error at line 2, col 8: Assertion failed: math is broken\n" +
+		"PASS " + filepath.Join(dir, "math_test.npp") + "\n" +
+		"1 passed, 1 failed\n"
+	if status != 1 || output != expected {
+		t.Errorf("status %d, output:\n%s\nWant status 1, output:\n%s", status, output, expected)
+	}
+
+	output = captureStdout(t, func() { status = runSuite(dir, "math_*.npp") })
+	if status != 0 || !strings.HasSuffix(output, "1 passed, 0 failed\n") {
+		t.Errorf("status %d, output:\n%s\nWant status 0 and all passing", status, output)
+	}
+
+	output = captureStdout(t, func() { status = runSuite(dir, "*.npp") })
+	if status != 1 || !strings.HasSuffix(output, "1 passed, 2 failed\n") {
+		t.Errorf("status %d, output:\n%s\nWant helper.npp to run and fail too", status, output)
+	}
+}
+
+func TestPrintf(t *testing.T) {
+	tests := []struct {
+		code     string
+		expected string
+	}{
+		{`suna printf("%d-%s", 1, "x");`, "1-x\n"},
+		{`suna printf("%v|%v|%s", [1, "a"], yas, 2.5);`, "[1, \"a\"]|yas|2.5\n"},
+		{`suna printf("100%% %s", "done"), printf("plain");`, "100% done plain\n"},
+		{`sun line = printf("%s scored %d", "asha", 42); suna len(line);`, "14\n"},
+		{`suna printf("%d", "x");`, "Error at line 1, col 13: printf %d expects an int, got string\n"},
+		{`suna printf("%d %d", 1);`, "Error at line 1, col 13: printf format has 2 verb(s) but got 1 argument(s)\n"},
+		{`suna printf("%d", 1, 2);`, "Error at line 1, col 13: printf format has 1 verb(s) but got 2 argument(s)\n"},
+		{`suna printf("%q", 1);`, "Error at line 1, col 13: printf does not support %q\n"},
+		{`suna printf("50%");`, "Error at line 1, col 13: printf format ends with a lone %\n"},
+		{`suna printf(5);`, "Error at line 1, col 13: printf expects a format string, got int\n"},
+	}
+	for _, tt := range tests {
+		output := runNPP(t, tt.code)
+		if output != tt.expected {
+			t.Errorf("%s\nGot:\n%q\nWant:%q", tt.code, output, tt.expected)
+		}
+	}
+}
+
+func TestFloatDivisionByZero(t *testing.T) {
+	tests := []struct {
+		code     string
+		expected string
+	}{
+		{"suna 1.0 / 0.0;", "Error at line 1, col 11: Division by zero\n"},
+		{"suna 0.0 / 0.0;", "Error at line 1, col 11: Division by zero\n"},
+		{"suna -1.0 / 0.0;", "Error at line 1, col 12: Division by zero\n"},
+		{"suna 1.0 / -0.0;", "Error at line 1, col 11: Division by zero\n"},
+		{"sun zero = 0; suna 2.5 / zero;", "Error at line 1, col 25: Division by zero\n"},
+		{"koshish { sun r = 1.0 / 0.0; } pakad (e) { suna \"caught\"; }", "caught\n"},
+		{"suna 0.0 / 1.0, -0.0, 1.0 / 4;", "0.0 0.0 0.25\n"},
+	}
+	for _, tt := range tests {
+		output := runNPP(t, tt.code)
+		if output != tt.expected {
+			t.Errorf("%s\nGot:\n%q\nWant:%q", tt.code, output, tt.expected)
+		}
+	}
+}
+
+func TestNullEquality(t *testing.T) {
+	tests := []struct {
+		code     string
+		expected string
+	}{
+		{`suna khali, type(khali);`, "khali null\n"},
+		{`suna khali == khali, khali != khali;`, "yas nah\n"},
+		{`sun x = khali; agar x == khali { suna "empty"; }`, "empty\n"},
+		{`suna 0 == khali, "" == khali, nah == khali, [] == khali, {} == khali;`, "nah nah nah nah nah\n"},
+		{`suna khali != 0, khali != "", khali != nah, khali != 1.5;`, "yas yas yas yas\n"},
+		{`suna from_json("null") == khali, [khali] == [khali];`, "yas yas\n"},
+		{`glow nothing() { fhek; } suna nothing() == khali;`, "yas\n"},
+		{`suna khali < 1;`, "Error at line 1, col 13: Cannot order null and int with <\n"},
+	}
+	for _, tt := range tests {
+		output := runNPP(t, tt.code)
+		if output != tt.expected {
+			t.Errorf("%s\nGot:\n%q\nWant:%q", tt.code, output, tt.expected)
+		}
+	}
+}
+
+func TestStringComparison(t *testing.T) {
+	tests := []struct {
+		code     string
+		expected string
+	}{
+		{`sun name = "bob"; agar name == "bob" { suna "hi bob"; }`, "hi bob\n"},
+		{`suna "a" == "a", "a" == "b", "a" != "b", "" == "";`, "yas nah yas yas\n"},
+		{`suna "apple" < "banana", "apple" > "banana", "b" >= "b", "ab" <= "a";`, "yas nah yas nah\n"},
+		{`suna "Z" < "a", "10" < "9", "ab" < "abc";`, "yas yas yas\n"},
+		{`suna type("a" == "a");`, "bool\n"},
+		{`suna "5" == 5;`, "Error at line 1, col 11: Invalid operation == between 5 and 5\n"},
+		{`suna "a" - "b";`, "Error at line 1, col 11: Invalid operation - between a and b\n"},
+	}
+	for _, tt := range tests {
+		output := runNPP(t, tt.code)
+		if output != tt.expected {
+			t.Errorf("%s\nGot:\n%q\nWant:%q", tt.code, output, tt.expected)
+		}
+	}
+}
+
+func TestSafeIndex(t *testing.T) {
+	tests := []struct {
+		code     string
+		expected string
+	}{
+		{`sun a = [1, 2, 3]; suna a?[1], a?[3], a?[-1];`, "2 khali khali\n"},
+		{`sun a = []; agar a?[0] == khali { suna "empty"; }`, "empty\n"},
+		{`sun h = {"x": 1}; suna h?["x"], h?["y"];`, "1 khali\n"},
+		{`suna "héllo"?[1], "ab"?[5];`, "é khali\n"},
+		{`sun n = khali; suna n?[fhenk("index ran")];`, "khali\n"},
+		{`sun grid = [[1, 2]]; suna grid?[0]?[1], grid?[0]?[5], grid?[3]?[0];`, "2 khali khali\n"},
+		{`suna yas ? [1] : [2];`, "[1]\n"},
+		{`sun a = [1]; suna a?[5]; suna a[5];`, "khali\nError at line 1, col 33: Index 5 out of range for length 1 (valid: 0 to 0)\n"},
+		{`suna 5?[0];`, "Error at line 1, col 8: Index operator not supported on 5\n"},
+		{`suna [1]?["a"];`, "Error at line 1, col 10: Array index must be an int, got a\n"},
+	}
+	for _, tt := range tests {
+		output := runNPP(t, tt.code)
+		if output != tt.expected {
+			t.Errorf("%s\nGot:\n%q\nWant:%q", tt.code, output, tt.expected)
+		}
+	}
+	program := parser.New(lexer.New(`suna grid?[0][1];`), false).ParseProgram()
+	if got := program.String(); got != "suna ((grid?[0])[1])\n" {
+		t.Errorf("got AST %q", got)
+	}
+}
+
+func TestMalformedStatementsDontRun(t *testing.T) {
+	for code, want := range map[string]string{
+		`koshish suna 1`:              "Expected { after koshish, got SUNA",
+		`koshish { } pakad { }`:       "Expected (name) after pakad, got {",
+		`koshish { suna 1; }`:         "Expected pakad or aakhir after koshish block",
+		`grind yas suna 1;`:           "Expected { after condition",
+		`baar 3 suna 1;`:              "Expected { after count",
+		`bahar: grind yas suna 1;`:    "Expected { after condition",
+		`agar yas { koshish suna 1 }`: "Expected { after koshish, got SUNA",
+		`{ koshish { } pakad { } }`:   "Expected (name) after pakad, got {",
+	} {
+		checkOnlyParseErrors(t, code, runMalformed(t, code), want)
+	}
+}
+
+func TestParserOut(t *testing.T) {
+	var out strings.Builder
+	stdout := captureStdout(t, func() {
+		p := parser.New(lexer.New("suna 1;\nsun = 2;\nsuna 3;"), false)
+		p.Out = &out
+		program := p.ParseProgram()
+		i := core.New()
+		i.Out = &out
+		i.Interpret(program)
+	})
+	if stdout != "" {
+		t.Errorf("nothing should reach stdout, got %q", stdout)
+	}
+	want := "Error at line 2, col 6: Expected identifier after SUN, got = // My grandma codes better!\n"
+	if !strings.HasPrefix(out.String(), want) || !strings.HasSuffix(out.String(), "1\n3\n") {
+		t.Errorf("got %q, want the syntax errors starting with %q followed by the program's output", out.String(), want)
+	}
+}
+
+func TestMisplacedBranchDoesntRun(t *testing.T) {
+	for code, want := range map[string]string{
+		`tod;`:                          "tod used outside of a loop",
+		`agla;`:                         "agla used outside of a loop",
+		`agar yas { tod; }`:             "tod used outside of a loop",
+		`grind yas { tod nope; }`:       "Unknown loop label 'nope'",
+		`bahar: baar 2 { agla andar; }`: "Unknown loop label 'andar'",
+		`agar { tod; }`:                 "Expected number, string, or identifier, got TOD",
+		`suna;`:                         "Expected number, string, or identifier, got ;",
+	} {
+		checkOnlyParseErrors(t, code, runMalformed(t, code), want)
+	}
+
+	// Without semicolons, the statement after a misplaced tod or agla must
+	// still parse and run, with no second error.
+	outside := " used outside of a loop // Nothing to escape from, genius!\n"
+	for code, want := range map[string]string{
+		"tod\nsuna \"next\"":                           "Error at line 2, col 1: tod" + outside + "next\n",
+		"agar yas { agla }\nsuna 2":                    "Error at line 1, col 17: agla" + outside + "2\n",
+		"koshish { tod } pakad (e) {}\nsuna \"after\"": "Error at line 1, col 15: tod" + outside + "after\n",
+		"bahar: baar 1 { tod nope }\nsuna 1":           "Error at line 1, col 26: Unknown loop label 'nope' // Which loop, genius?\n1\n",
+	} {
+		if got := runMalformed(t, code); got != want {
+			t.Errorf("%q: got %q, want %q", code, got, want)
+		}
+	}
+
+	// A name on the line after a bare tod or agla is a new statement, not a label.
+	code := `glow f(n) { suna n; }
+sun n = 0
+grind n < 2 {
+    sun n = n + 1
+    f(n)
+    agla
+    f(0)
+}
+baar 1 {
+    tod
+    f(9)
+}
+suna "done"`
+	if got := runMalformed(t, code); got != "1\n2\ndone\n" {
+		t.Errorf("got %q", got)
+	}
+
+	// A dropped loop is read to its closing brace, so what follows still runs.
+	want := "Error at line 1, col 22: Unknown loop label 'nope' // Which loop, genius?\n1\n"
+	if got := runMalformed(t, `grind yas { tod nope; } suna 1;`); got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+
+	// Coverage walks the AST before running it, so it must not meet a nil either.
+	path := filepath.Join(t.TempDir(), "broken.npp")
+	if err := os.WriteFile(path, []byte("agar { }\ntod;\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	captureStdout(t, func() {
+		defer func() {
+			if r := recover(); r != nil {
+				t.Errorf("cover panicked: %v", r)
+			}
+		}()
+		cover([]string{path})
+	})
+}
+
+func TestBranchInFunctionBody(t *testing.T) {
+	for code, want := range map[string]string{
+		`glow f() { tod; }`:                          "tod used outside of a loop",
+		`glow f() { agar yas { agla; } }`:            "agla used outside of a loop",
+		`grind yas { glow g() { tod; } }`:            "tod used outside of a loop",
+		`bahar: baar 2 { glow g() { agla bahar; } }`: "agla used outside of a loop",
+	} {
+		checkOnlyParseErrors(t, code, runMalformed(t, code), want)
+	}
+
+	// The bad statement is dropped, so f still exists and returns khali, and
+	// the loop around g, which lost its tod, is dropped rather than spinning.
+	want := "Error at line 1, col 16: tod used outside of a loop // Nothing to escape from, genius!\n" +
+		"Error at line 2, col 28: tod used outside of a loop // Nothing to escape from, genius!\n" +
+		"khali\ndone\n"
+	code := "glow f() { tod; } suna f();\ngrind yas { glow g() { tod; } g(); tod; } suna \"done\";"
+	if got := runMalformed(t, code); got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+
+	// fhek outside a function is reported once, at fhek, and its value is
+	// read with it, so the next statement still runs.
+	outside := " fhek used outside of a function // Nowhere to throw it back to, genius!\n"
+	for code, want := range map[string]string{
+		"fhek 1\nsuna \"next\"":        "Error at line 1, col 6:" + outside + "next\n",
+		"fhek 1, 2 + 3\nsuna \"next\"": "Error at line 1, col 6:" + outside + "next\n",
+		"agar yas { fhek }\nsuna 2":    "Error at line 1, col 17:" + outside + "2\n",
+		"fhek\nsuna \"next\"":          "Error at line 2, col 1:" + outside + "next\n",
+	} {
+		if got := runMalformed(t, code); got != want {
+			t.Errorf("%q: got %q, want %q", code, got, want)
+		}
+	}
+	// Inside a function, a bare fhek doesn't take the next line as its value.
+	if got := runMalformed(t, "glow f() {\n    fhek\n    suna \"unreached\"\n}\nsuna f()"); got != "khali\n" {
+		t.Errorf("got %q", got)
+	}
+
+	// A loop inside the function body is still fine to leave.
+	for code, want := range map[string]string{
+		`glow f() { baar 3 { tod; } fhek 1; } suna f();`:                   "1\n",
+		`glow f(n) { sun s = 0; baar n { agla; } fhek s; } suna f(2);`:     "0\n",
+		`grind yas { glow g() { baar 2 { tod; } } g(); tod; } suna "out";`: "out\n",
+	} {
+		if got := runNPP(t, code); got != want {
+			t.Errorf("%s: got %q, want %q", code, got, want)
+		}
+	}
+}
+
+func TestFormatKeepsComments(t *testing.T) {
+	input := `// Seconds in an hour.
+sun secs = 60*60;   // trailing comments are dropped
+// add sums two numbers.
+//
+//   Indented detail survives.
+glow add(a,b){ fhek a+b }
+glow pair() { fhek 1, -2 }
+sun x, y = pair();
+// outer loop
+bahar: baar 3 {
+  // bump x
+  sun x = x + 1
+  agar x == 2 { agla bahar; } magar agar x > 5 { tod } magar { chhod }
+}
+suna (1 + 2) * 3, 1 - (2 - 3), -(-y), !(x == y), x > 1 ? "a\tb" : "c";
+koshish { fhenk("boom") } pakad (e) { suna "caught" } aakhir { suna secs, add(x, y) }
+// the end
+`
+	want := `// Seconds in an hour.
+sun secs = 60 * 60;
+
+// add sums two numbers.
+//
+//   Indented detail survives.
+glow add(a, b) {
+    fhek a + b;
+}
+
+glow pair() {
+    fhek 1, -2;
+}
+
+sun x, y = pair();
+
+// outer loop
+bahar: baar 3 {
+    // bump x
+    sun x = x + 1;
+    agar x == 2 {
+        agla bahar;
+    } magar agar x > 5 {
+        tod;
+    } magar {
+        chhod;
+    }
+}
+suna (1 + 2) * 3, 1 - (2 - 3), -(-y), !(x == y), x > 1 ? "a\tb" : "c";
+koshish {
+    fhenk("boom");
+} pakad (e) {
+    suna "caught";
+} aakhir {
+    suna secs, add(x, y);
+}
+// the end
+`
+	got, errs := parser.Format(input)
+	if len(errs) > 0 {
+		t.Fatalf("unexpected errors: %v", errs)
+	}
+	if got != want {
+		t.Fatalf("Format:\n%s\nwant:\n%s", got, want)
+	}
+	if again, _ := parser.Format(got); again != got {
+		t.Errorf("formatting twice changed the output:\n%s", again)
+	}
+	if before, after := runNPP(t, input), runNPP(t, got); before != after {
+		t.Errorf("formatted program prints %q, original prints %q", after, before)
+	}
+
+	if out, errs := parser.Format("sun = 1;"); len(errs) == 0 || out != "" {
+		t.Errorf("got %q and errors %v, want only errors", out, errs)
+	}
+	path := filepath.Join(t.TempDir(), "fmt.npp")
+	if err := os.WriteFile(path, []byte(input), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	var status int
+	if out := captureStdout(t, func() { status = format([]string{path}) }); status != 0 || out != want {
+		t.Errorf("format returned %d with output %q", status, out)
+	}
+}
+
+func TestCoverKeepsFilesApart(t *testing.T) {
+	dir := t.TempDir()
+	first, second := filepath.Join(dir, "first.npp"), filepath.Join(dir, "second.npp")
+	// The suna statements sit at the same line and column in both files,
+	// but only the one in second.npp runs.
+	if err := os.WriteFile(first, []byte("sun x = 1;\nagar x == 9 { suna 1; }\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(second, []byte("sun y = 2;\nagar x != 9 { suna 2; }\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	got := captureStdout(t, func() { cover([]string{first, second}) })
+	want := "2\n----- coverage -----\n" +
+		first + ": line 1: covered\n" +
+		first + ": line 2: partial (1/2 statements)\n" +
+		second + ": line 1: covered\n" +
+		second + ": line 2: covered\n" +
+		"5/6 statements covered (83.3%)\n"
+	if got != want {
+		t.Errorf("got:\n%s\nwant:\n%s", got, want)
+	}
+}
+
+// countingProgram builds a straight-line program that counts up to n.
+func countingProgram(n int) string {
+	var sb strings.Builder
+	sb.WriteString("sun i = 0;\n")
+	for range n {
+		sb.WriteString("sun i = i + 1;\nsun small = i % 100;\n")
+	}
+	return sb.String()
+}
+
+func BenchmarkCountingProgram(b *testing.B) {
+	program := parser.New(lexer.New(countingProgram(200)), false).ParseProgram()
+	b.ReportAllocs()
+	for b.Loop() {
+		core.New().Interpret(program)
+	}
+}
@@ -0,0 +1,67 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"time"
+)
+
+// fileWatcher blocks in Wait until one of the watched files changes.
+type fileWatcher interface {
+	Wait() error
+}
+
+// pollWatcher detects changes by polling file modification times, which
+// avoids depending on platform-specific notification APIs.
+type pollWatcher struct {
+	paths    []string
+	interval time.Duration
+	modTimes map[string]time.Time
+}
+
+// newPollWatcher records the current modification times of paths.
+func newPollWatcher(paths []string, interval time.Duration) (*pollWatcher, error) {
+	w := &pollWatcher{paths: paths, interval: interval, modTimes: make(map[string]time.Time)}
+	for _, path := range paths {
+		info, err := os.Stat(path)
+		if err != nil {
+			return nil, err
+		}
+		w.modTimes[path] = info.ModTime()
+	}
+	return w, nil
+}
+
+// Wait polls until a watched file's modification time differs from the last one seen.
+func (w *pollWatcher) Wait() error {
+	for {
+		time.Sleep(w.interval)
+		changed := false
+		for _, path := range w.paths {
+			info, err := os.Stat(path)
+			if err != nil {
+				return err
+			}
+			if !info.ModTime().Equal(w.modTimes[path]) {
+				w.modTimes[path] = info.ModTime()
+				changed = true
+			}
+		}
+		if changed {
+			return nil
+		}
+	}
+}
+
+// watch runs the program, then re-runs it after every change reported by w
+// until w returns an error.
+func watch(filePaths []string, w fileWatcher) {
+	for {
+		run(filePaths)
+		if err := w.Wait(); err != nil {
+			fmt.Println("Stopped watching:", err)
+			return
+		}
+		fmt.Println("----- file changed, re-running -----")
+	}
+}